@@ -1,5 +1,7 @@
 package bitmap
 
+import "math/bits"
+
 // Bitmap ...
 type Bitmap struct {
 	bits []byte
@@ -53,3 +55,39 @@ func (p *Bitmap) ResetMax(max uint) {
 func (p *Bitmap) Max() uint {
 	return p.max
 }
+
+// Count returns the number of set bits.
+func (p *Bitmap) Count() int {
+	n := 0
+	for _, b := range p.bits {
+		n += bits.OnesCount8(b)
+	}
+	return n
+}
+
+// Union returns a new Bitmap holding the bits set in either p or o.
+func (p *Bitmap) Union(o *Bitmap) *Bitmap {
+	a, b := p, o
+	if b.max > a.max {
+		a, b = b, a
+	}
+	dst := NewBitmap(a.max)
+	copy(dst.bits, a.bits)
+	for i, v := range b.bits {
+		dst.bits[i] |= v
+	}
+	return dst
+}
+
+// Intersection returns a new Bitmap holding the bits set in both p and o.
+func (p *Bitmap) Intersection(o *Bitmap) *Bitmap {
+	max := p.max
+	if o.max < max {
+		max = o.max
+	}
+	dst := NewBitmap(max)
+	for i := range dst.bits {
+		dst.bits[i] = p.bits[i] & o.bits[i]
+	}
+	return dst
+}
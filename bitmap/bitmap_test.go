@@ -43,3 +43,25 @@ func TestBitmap(t *testing.T) {
 		}
 	}
 }
+
+func TestBitmapAlgebra(t *testing.T) {
+	bm1 := bitmap.NewBitmap(20)
+	bm2 := bitmap.NewBitmap(100)
+	for _, v := range []uint{1, 5, 9, 20} {
+		bm1.Set(v)
+	}
+	for _, v := range []uint{5, 9, 30, 100} {
+		bm2.Set(v)
+	}
+	if bm1.Count() != 4 {
+		t.Fatal(bm1.Count())
+	}
+	union := bm1.Union(bm2)
+	if union.Max() != 100 || union.Count() != 6 {
+		t.Fatal(union.Max(), union.Count())
+	}
+	ins := bm2.Intersection(bm1)
+	if ins.Max() != 20 || ins.Count() != 2 || !ins.Has(5) || !ins.Has(9) {
+		t.Fatal(ins.Max(), ins.Count())
+	}
+}
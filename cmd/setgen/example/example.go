@@ -0,0 +1,4 @@
+// Package example holds a setgen generated typed set of int.
+package example
+
+//go:generate go run .. -type int -name IntSet -package example -o intset_gen.go
@@ -0,0 +1,84 @@
+// Code generated by setgen; DO NOT EDIT.
+
+package example
+
+import "github.com/jettyu/gosc/set"
+
+// IntSet is a typed set of int.
+type IntSet struct {
+	set set.Set
+}
+
+// NewIntSet returns the elements of arr ordered by less. A nil
+// less orders by <.
+func NewIntSet(arr []int, less func(a, b int) bool) *IntSet {
+	if arr == nil {
+		arr = []int{}
+	}
+	if less == nil {
+		less = func(a, b int) bool { return a < b }
+	}
+	return &IntSet{
+		set: set.New(arr, func(s1, s2 interface{}) bool {
+			return less(s1.(int), s2.(int))
+		}),
+	}
+}
+
+// Set returns the underlying untyped set.
+func (p *IntSet) Set() set.Set {
+	return p.set
+}
+
+// Len ...
+func (p *IntSet) Len() int {
+	return p.set.Len()
+}
+
+// Slice ...
+func (p *IntSet) Slice() []int {
+	return p.set.Slice().([]int)
+}
+
+// Search ...
+func (p *IntSet) Search(v int, pos int) int {
+	return p.set.Search(v, pos)
+}
+
+// Has ...
+func (p *IntSet) Has(v int) bool {
+	return p.set.Has(v, 0)
+}
+
+// Insert ...
+func (p *IntSet) Insert(v ...int) int {
+	return p.set.Insert(p.args(v)...)
+}
+
+// Replace ...
+func (p *IntSet) Replace(v ...int) int {
+	return p.set.Replace(p.args(v)...)
+}
+
+// Erase ...
+func (p *IntSet) Erase(v ...int) int {
+	return p.set.Erase(p.args(v)...)
+}
+
+// Equal ...
+func (p *IntSet) Equal(arr []int) bool {
+	return p.set.Equal(arr)
+}
+
+// Clone ...
+func (p *IntSet) Clone() *IntSet {
+	return &IntSet{set: p.set.Clone()}
+}
+
+func (p *IntSet) args(v []int) []interface{} {
+	args := make([]interface{}, len(v))
+	for i := range v {
+		args[i] = v[i]
+	}
+	return args
+}
@@ -0,0 +1,37 @@
+package example_test
+
+import (
+	"testing"
+
+	"github.com/jettyu/gosc/cmd/setgen/example"
+)
+
+func TestIntSet(t *testing.T) {
+	s := example.NewIntSet([]int{3, 1, 2, 3}, nil)
+	if !s.Equal([]int{1, 2, 3}) {
+		t.Fatal(s.Slice())
+	}
+	if !s.Has(2) || s.Has(4) {
+		t.Fatal(s.Slice())
+	}
+	if s.Insert(4, 5, 1) != 2 {
+		t.Fatal(s.Slice())
+	}
+	if s.Erase(1, 9) != 1 {
+		t.Fatal(s.Slice())
+	}
+	clone := s.Clone()
+	clone.Erase(5)
+	if s.Len() != 4 || clone.Len() != 3 {
+		t.Fatal(s.Slice(), clone.Slice())
+	}
+	if s.Search(4, 0) != 2 {
+		t.Fatal(s.Slice())
+	}
+
+	desc := example.NewIntSet(nil, func(a, b int) bool { return a > b })
+	desc.Insert(1, 3, 2)
+	if got := desc.Slice(); len(got) != 3 || got[0] != 3 || got[2] != 1 {
+		t.Fatal(got)
+	}
+}
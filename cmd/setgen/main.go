@@ -0,0 +1,175 @@
+// Command setgen generates a typed wrapper around set.Set for one element
+// type, so callers get compile-time typed methods without generics.
+//
+//	//go:generate setgen -type int -name IntSet -package example -o intset_gen.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+)
+
+var ordered = map[string]bool{
+	"string": true,
+	"int":    true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"byte": true, "rune": true, "uintptr": true,
+	"float32": true, "float64": true,
+}
+
+type params struct {
+	Package string
+	Type    string
+	Name    string
+	Ordered bool
+	Import  bool
+}
+
+func main() {
+	var (
+		typ  = flag.String("type", "", "element type, required")
+		name = flag.String("name", "", "wrapper type name, default <Type>Set")
+		pkg  = flag.String("package", os.Getenv("GOPACKAGE"), "package name of the generated file")
+		out  = flag.String("o", "", "output file, default stdout")
+	)
+	flag.Parse()
+	if *typ == "" || *pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	p := params{
+		Package: *pkg,
+		Type:    *typ,
+		Name:    *name,
+		Ordered: ordered[*typ],
+		Import:  *pkg != "set",
+	}
+	if p.Name == "" {
+		p.Name = exported(*typ) + "Set"
+	}
+	src, err := generate(p)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	// ioutil, as os.WriteFile needs Go 1.16 and go.mod targets Go 1.12
+	if err = ioutil.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func exported(typ string) string {
+	typ = typ[strings.LastIndex(typ, ".")+1:]
+	typ = strings.TrimLeft(typ, "*[]")
+	return strings.ToUpper(typ[:1]) + typ[1:]
+}
+
+func generate(p params) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, p); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %v", err)
+	}
+	return src, nil
+}
+
+var tmpl = template.Must(template.New("setgen").Parse(`// Code generated by setgen; DO NOT EDIT.
+
+package {{.Package}}
+
+{{if .Import}}import "github.com/jettyu/gosc/set"{{end}}
+
+// {{.Name}} is a typed set of {{.Type}}.
+type {{.Name}} struct {
+	set {{if .Import}}set.{{end}}Set
+}
+
+// New{{.Name}} returns the elements of arr ordered by less.{{if .Ordered}} A nil
+// less orders by <.{{end}}
+func New{{.Name}}(arr []{{.Type}}, less func(a, b {{.Type}}) bool) *{{.Name}} {
+	if arr == nil {
+		arr = []{{.Type}}{}
+	}
+	{{- if .Ordered}}
+	if less == nil {
+		less = func(a, b {{.Type}}) bool { return a < b }
+	}
+	{{- end}}
+	return &{{.Name}}{
+		set: {{if .Import}}set.{{end}}New(arr, func(s1, s2 interface{}) bool {
+			return less(s1.({{.Type}}), s2.({{.Type}}))
+		}),
+	}
+}
+
+// Set returns the underlying untyped set.
+func (p *{{.Name}}) Set() {{if .Import}}set.{{end}}Set {
+	return p.set
+}
+
+// Len ...
+func (p *{{.Name}}) Len() int {
+	return p.set.Len()
+}
+
+// Slice ...
+func (p *{{.Name}}) Slice() []{{.Type}} {
+	return p.set.Slice().([]{{.Type}})
+}
+
+// Search ...
+func (p *{{.Name}}) Search(v {{.Type}}, pos int) int {
+	return p.set.Search(v, pos)
+}
+
+// Has ...
+func (p *{{.Name}}) Has(v {{.Type}}) bool {
+	return p.set.Has(v, 0)
+}
+
+// Insert ...
+func (p *{{.Name}}) Insert(v ...{{.Type}}) int {
+	return p.set.Insert(p.args(v)...)
+}
+
+// Replace ...
+func (p *{{.Name}}) Replace(v ...{{.Type}}) int {
+	return p.set.Replace(p.args(v)...)
+}
+
+// Erase ...
+func (p *{{.Name}}) Erase(v ...{{.Type}}) int {
+	return p.set.Erase(p.args(v)...)
+}
+
+// Equal ...
+func (p *{{.Name}}) Equal(arr []{{.Type}}) bool {
+	return p.set.Equal(arr)
+}
+
+// Clone ...
+func (p *{{.Name}}) Clone() *{{.Name}} {
+	return &{{.Name}}{set: p.set.Clone()}
+}
+
+func (p *{{.Name}}) args(v []{{.Type}}) []interface{} {
+	args := make([]interface{}, len(v))
+	for i := range v {
+		args[i] = v[i]
+	}
+	return args
+}
+`))
@@ -0,0 +1,32 @@
+package set_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestApproxDistinct(t *testing.T) {
+	a := set.NewApproxDistinct(func(v interface{}) uint64 { return uint64(v.(int)) })
+	if n := a.EstimateDistinct(); n != 0 {
+		t.Fatal(n)
+	}
+	check := func(want int) {
+		// 4 standard errors of a 2^14 register sketch
+		if n := a.EstimateDistinct(); math.Abs(float64(n-want)) > 4*0.0081*float64(want) {
+			t.Fatal(n, want)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		a.Add(i)
+		a.Add(i)
+	}
+	check(1000)
+	for r := 0; r < 3; r++ {
+		for i := 0; i < 200000; i++ {
+			a.Add(i)
+		}
+	}
+	check(200000)
+}
@@ -0,0 +1,51 @@
+package set
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/jettyu/gosc/bitmap"
+)
+
+// MaxBitsetValue is the largest element ToBitset accepts.
+const MaxBitsetValue = 1<<26 - 1
+
+var (
+	// ErrNotInts ...
+	ErrNotInts = errors.New("set: not a set of int")
+	// ErrBitsetRange ...
+	ErrBitsetRange = errors.New("set: element out of bitset range")
+)
+
+// ToBitset converts a set of int into a bitmap, erroring for negative
+// elements or elements above MaxBitsetValue.
+func ToBitset(s Set) (*bitmap.Bitmap, error) {
+	arr, ok := s.Slice().([]int)
+	if !ok {
+		return nil, ErrNotInts
+	}
+	if len(arr) == 0 {
+		return bitmap.NewBitmap(0), nil
+	}
+	if arr[0] < 0 || arr[len(arr)-1] > MaxBitsetValue {
+		return nil, ErrBitsetRange
+	}
+	bm := bitmap.NewBitmap(uint(arr[len(arr)-1]))
+	for _, v := range arr {
+		bm.Set(uint(v))
+	}
+	return bm, nil
+}
+
+// FromBitset converts a bitmap back into a set of int.
+func FromBitset(bm *bitmap.Bitmap) Set {
+	arr := make([]int, 0, bm.Count())
+	for i := uint(0); i <= bm.Max(); i++ {
+		if bm.Has(i) {
+			arr = append(arr, int(i))
+		}
+	}
+	s := Ints([]int{}).(*set)
+	s.adopt(reflect.ValueOf(arr))
+	return s
+}
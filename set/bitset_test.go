@@ -0,0 +1,52 @@
+package set_test
+
+import (
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestBitset(t *testing.T) {
+	arr1 := []int{}
+	arr2 := []int{}
+	for i := 0; i < 300; i++ {
+		if i%3 != 0 {
+			arr1 = append(arr1, i)
+		}
+		if i%2 == 0 {
+			arr2 = append(arr2, i)
+		}
+	}
+	s1, s2 := set.Ints(arr1), set.Ints(arr2)
+	bm1, err := set.ToBitset(s1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bm1.Count() != s1.Len() {
+		t.Fatal(bm1.Count())
+	}
+	if !set.FromBitset(bm1).Equal(s1.Slice()) {
+		t.Fatal(set.FromBitset(bm1).Slice())
+	}
+	bm2, err := set.ToBitset(s2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins := set.FromBitset(bm1.Intersection(bm2))
+	if !ins.Equal(s1.Intersection(s2).Slice()) {
+		t.Fatal(ins.Slice())
+	}
+	if bm1.Union(bm2).Count() != 250 {
+		t.Fatal(bm1.Union(bm2).Count())
+	}
+
+	if _, err = set.ToBitset(set.Ints([]int{-1, 2})); err != set.ErrBitsetRange {
+		t.Fatal(err)
+	}
+	if _, err = set.ToBitset(set.Ints([]int{set.MaxBitsetValue + 1})); err != set.ErrBitsetRange {
+		t.Fatal(err)
+	}
+	if _, err = set.ToBitset(set.Strings([]string{"1"})); err != set.ErrNotInts {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,267 @@
+package set
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Eviction selects which element a full bounded set drops.
+type Eviction int
+
+// Eviction policies
+const (
+	// EvictMin drops the smallest element, keeping the largest ones.
+	EvictMin Eviction = iota
+	// EvictMax drops the largest element, keeping the smallest ones.
+	EvictMax
+)
+
+type boundedSet struct {
+	*set
+	max   int
+	evict Eviction
+}
+
+// NewBounded returns a set holding at most max elements. Inserting an
+// element into a full set evicts one according to evict, or drops the
+// element if it would be the one evicted. Sets derived from it, other than
+// by Clone, are not bounded.
+func NewBounded(max int, evict Eviction, slice interface{},
+	less func(s1, s2 interface{}) bool,
+	equal ...func(s1, s2 interface{}) bool,
+) Set {
+	p := &boundedSet{
+		set:   New(nil, less, equal...).(*set),
+		max:   max,
+		evict: evict,
+	}
+	if slice != nil {
+		p.init(reflect.TypeOf(slice))
+		p.Insert(slice)
+	}
+	return p
+}
+
+func (p *boundedSet) Insert(v ...interface{}) (added int) {
+	for _, arg := range v {
+		arg = p.normalize(arg)
+		if isNil(arg) {
+			continue
+		}
+		if rv := reflect.ValueOf(arg); rv.Kind() == reflect.Slice {
+			added += p.insertSlice(dropNil(rv).Interface())
+			continue
+		}
+		added += p.insertOne(arg)
+	}
+	return
+}
+
+func (p *boundedSet) InsertE(v ...interface{}) (added int, err error) {
+	err = p.each(v, func(e interface{}) error {
+		if err := p.check(e); err != nil {
+			return err
+		}
+		added += p.insertOne(e)
+		return nil
+	})
+	return
+}
+
+func (p *boundedSet) Replace(v ...interface{}) (replaced int) {
+	for _, arg := range v {
+		arg = p.normalize(arg)
+		rv := reflect.ValueOf(arg)
+		if rv.Kind() != reflect.Slice {
+			rv = reflect.ValueOf([]interface{}{arg})
+		}
+		for i := 0; i < rv.Len(); i++ {
+			e := rv.Index(i).Interface()
+			if isNil(e) {
+				continue
+			}
+			if p.set.Has(e, 0) {
+				p.set.ReplaceOne(e)
+				continue
+			}
+			replaced += p.insertOne(e)
+		}
+	}
+	return
+}
+
+func (p *boundedSet) InsertIfAbsent(v interface{}) bool {
+	v = p.normalize(v)
+	if p.hasOne(v, 0) {
+		return false
+	}
+	return p.insertOne(v) == 1
+}
+
+func (p *boundedSet) Toggle(v interface{}) (nowPresent bool) {
+	v = p.normalize(v)
+	if p.EraseOne(v) == 1 {
+		return false
+	}
+	return p.insertOne(v) == 1
+}
+
+func (p *boundedSet) Clone() Set {
+	return p.CloneWithCap(0)
+}
+
+func (p *boundedSet) CloneWithCap(extra int) Set {
+	return &boundedSet{
+		set:   p.set.CloneWithCap(extra).(*set),
+		max:   p.max,
+		evict: p.evict,
+	}
+}
+
+func (p *boundedSet) Update(fn func(s Set) error) error {
+	s := p.Clone().(*boundedSet)
+	if err := fn(s); err != nil {
+		return err
+	}
+	*p = *s
+	return nil
+}
+
+func (p *boundedSet) BatchInsertRCU(slice interface{}) int {
+	return p.Insert(slice)
+}
+
+// ApplyDiff counts evictions in the change in length.
+func (p *boundedSet) ApplyDiff(added, removed interface{}) (net int) {
+	n := p.Len()
+	if removed != nil {
+		p.Erase(removed)
+	}
+	if added != nil {
+		p.Insert(added)
+	}
+	return p.Len() - n
+}
+
+func (p *boundedSet) Reset(slice interface{}, less, equal func(s1, s2 interface{}) bool) {
+	p.set.Reset(nil, less, equal)
+	if slice == nil {
+		return
+	}
+	rv := reflect.ValueOf(slice)
+	arr := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+	reflect.Copy(arr, rv)
+	p.init(rv.Type())
+	p.Insert(arr.Interface())
+}
+
+// WouldAdd also reports false for an element a full set would drop.
+func (p *boundedSet) WouldAdd(v interface{}) bool {
+	return p.set.WouldAdd(v) && p.fits(p.normalize(v))
+}
+
+func (p *boundedSet) WouldChange(slice interface{}) bool {
+	return p.wouldChange(slice, func(v interface{}) bool { return p.admit(v) == nil && p.fits(v) })
+}
+
+// fits reports whether an absent v would be kept on insertion.
+func (p *boundedSet) fits(v interface{}) bool {
+	return p.max > 0 && (p.Len() < p.max || p.beats(v))
+}
+
+// beats reports whether v would survive the insertion into a full set.
+func (p *boundedSet) beats(v interface{}) bool {
+	if p.evict == EvictMin {
+		return p.less(p.rv.Index(0).Interface(), v)
+	}
+	return p.less(v, p.rv.Index(p.rv.Len()-1).Interface())
+}
+
+func (p *boundedSet) insertOne(v interface{}) int {
+	if p.max <= 0 {
+		return 0
+	}
+	if p.Len() < p.max {
+		return p.set.InsertOne(v)
+	}
+	if !p.beats(v) || p.set.InsertOne(v) == 0 {
+		return 0
+	}
+	if p.evict == EvictMin {
+		p.eraseAt(0)
+	} else {
+		p.eraseAt(p.rv.Len() - 1)
+	}
+	return 1
+}
+
+// insertSlice sorts the batch and skips the elements that cannot beat the
+// evicted end of a full set, without trying to insert them.
+func (p *boundedSet) insertSlice(slice interface{}) (added int) {
+	if p.max <= 0 {
+		return 0
+	}
+	p.init(reflect.TypeOf(slice))
+	p.sort(slice)
+	rv := reflect.ValueOf(slice)
+	i := 0
+	for i < rv.Len() {
+		if p.Len() < p.max {
+			added += p.insertOne(rv.Index(i).Interface())
+			i++
+			continue
+		}
+		if p.evict == EvictMin {
+			// the batch ascends, so skip the prefix not above the minimum
+			i += sort.Search(rv.Len()-i, func(n int) bool {
+				return p.beats(rv.Index(i + n).Interface())
+			})
+			if i < rv.Len() {
+				added += p.insertOne(rv.Index(i).Interface())
+				i++
+			}
+			continue
+		}
+		// the batch ascends, so nothing after an element not below the
+		// maximum can get in
+		if !p.beats(rv.Index(i).Interface()) {
+			break
+		}
+		added += p.insertOne(rv.Index(i).Interface())
+		i++
+	}
+	return
+}
+
+// TopN is a set keeping the n largest elements offered to it.
+type TopN interface {
+	Set
+	Offer(v interface{}) bool
+	Result() interface{}
+}
+
+type topN struct {
+	*boundedSet
+}
+
+// NewTopN returns an empty TopN ordered by less. Elements are compared by
+// reflect.DeepEqual for equality.
+func NewTopN(n int, less func(a, b interface{}) bool) TopN {
+	return &topN{NewBounded(n, EvictMin, nil, less).(*boundedSet)}
+}
+
+// Offer inserts v if it is among the n largest so far, and reports whether
+// it was. Once the set is full, an element not above the smallest kept one is
+// rejected with a single comparison.
+func (p *topN) Offer(v interface{}) bool {
+	v = p.normalize(v)
+	if p.max > 0 && p.Len() == p.max && !p.beats(v) {
+		return false
+	}
+	return p.insertOne(v) == 1
+}
+
+// Result returns a copy of the elements kept, in ascending order.
+func (p *topN) Result() interface{} {
+	return p.ToSlice()
+}
@@ -0,0 +1,135 @@
+package set_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestBounded(t *testing.T) {
+	top := set.NewBounded(3, set.EvictMin, []int{5, 1, 4}, intLess)
+	if !top.Equal([]int{1, 4, 5}) {
+		t.Fatal(top.Slice())
+	}
+	if top.Insert([]int{0, 2, 6, 3, 7}) != 4 {
+		t.Fatal(top.Slice())
+	}
+	if !top.Equal([]int{5, 6, 7}) {
+		t.Fatal(top.Slice())
+	}
+	if top.Insert(5, 1) != 0 || top.Insert(8) != 1 || !top.Equal([]int{6, 7, 8}) {
+		t.Fatal(top.Slice())
+	}
+
+	bottom := set.NewBounded(3, set.EvictMax, nil, intLess)
+	bottom.Insert([]int{9, 3, 8, 1, 7})
+	if !bottom.Equal([]int{1, 3, 7}) {
+		t.Fatal(bottom.Slice())
+	}
+	if bottom.Insert(2) != 1 || !bottom.Equal([]int{1, 2, 3}) {
+		t.Fatal(bottom.Slice())
+	}
+	if bottom.InsertIfAbsent(5) || bottom.InsertIfAbsent(1) || !bottom.Equal([]int{1, 2, 3}) {
+		t.Fatal(bottom.Slice())
+	}
+	if !bottom.InsertIfAbsent(0) || !bottom.Equal([]int{0, 1, 2}) {
+		t.Fatal(bottom.Slice())
+	}
+	bottom.Erase(0)
+	bottom.Insert(3)
+	clone := bottom.Clone()
+	if clone.Insert(0) != 1 || !clone.Equal([]int{0, 1, 2}) || !bottom.Equal([]int{1, 2, 3}) {
+		t.Fatal(clone.Slice(), bottom.Slice())
+	}
+}
+
+func TestBoundedWrites(t *testing.T) {
+	full := func() set.Set { return set.NewBounded(2, set.EvictMin, []int{1, 2}, intLess) }
+	check := func(s set.Set) {
+		t.Helper()
+		if s.Len() > 2 {
+			t.Fatal(s.Slice())
+		}
+	}
+	s := full()
+	if net := s.ApplyDiff([]int{7, 8, 9}, nil); net != 0 || !s.Equal([]int{8, 9}) {
+		t.Fatal(net, s.Slice())
+	}
+	check(s)
+	s = full()
+	s.BatchInsertRCU([]int{7, 8, 9})
+	check(s)
+	safe := set.NewSafe(full())
+	safe.BatchInsertRCU([]int{7, 8, 9})
+	check(safe)
+	s = full()
+	s.Reset([]int{5, 3, 4, 6}, intLess, nil)
+	check(s)
+	if !s.Equal([]int{5, 6}) {
+		t.Fatal(s.Slice())
+	}
+	s = full()
+	if s.WouldAdd(0) || !s.WouldAdd(3) || s.WouldChange([]int{0, 1, 2}) || !s.WouldChange([]int{0, 3}) {
+		t.Fatal(s.Slice())
+	}
+	if set.NewBounded(0, set.EvictMin, nil, intLess).WouldAdd(1) {
+		t.Fatal()
+	}
+}
+
+func TestBoundedZero(t *testing.T) {
+	for _, evict := range []set.Eviction{set.EvictMin, set.EvictMax} {
+		s := set.NewBounded(0, evict, []int{1, 2}, intLess)
+		if s.Len() != 0 || s.Insert([]int{3, 4}) != 0 || s.Insert(5) != 0 || s.Len() != 0 {
+			t.Fatal(evict, s.Slice())
+		}
+	}
+}
+
+func BenchmarkBoundedInsert(b *testing.B) {
+	batch := make([]int, 100000)
+	for i := range batch {
+		batch[i] = i
+	}
+	top := []int{1 << 30, 1<<30 + 1, 1<<30 + 2}
+	b.Run("skip", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			set.NewBounded(len(top), set.EvictMin, top, intLess).Insert(batch)
+		}
+	})
+	// insert and evict every element, as without the bounded fast path
+	b.Run("insert-evict", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s := set.Ints(append([]int(nil), top...))
+			for _, v := range batch {
+				s.Insert(v)
+				if s.Len() > len(top) {
+					s.Erase(s.Slice().([]int)[0])
+				}
+			}
+		}
+	})
+}
+
+func TestTopN(t *testing.T) {
+	top := set.NewTopN(5, intLess)
+	if top.Result() != nil {
+		t.Fatal(top.Result())
+	}
+	made := 0
+	for i := 0; i < 10000; i++ {
+		if top.Offer(i * 7919 % 10000) {
+			made++
+		}
+	}
+	if !reflect.DeepEqual(top.Result(), []int{9995, 9996, 9997, 9998, 9999}) || made < 5 || made > 10000 {
+		t.Fatal(top.Result(), made)
+	}
+	if top.Offer(9999) || top.Offer(5) || !top.Offer(10000) || top.Len() != 5 {
+		t.Fatal(top.Result())
+	}
+	if set.NewTopN(0, intLess).Offer(1) {
+		t.Fatal("zero")
+	}
+}
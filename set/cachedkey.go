@@ -0,0 +1,36 @@
+package set
+
+// NewCachedKey builds a set ordered by keyLess over keyOf of each element,
+// for comparators that are expensive to evaluate. The key of an element is
+// computed once on insert and cached alongside the backing slice, so
+// searches compute only the key of the value searched for. Elements with
+// equivalent keys are equal. Call ReSort after changing elements through
+// Slice to recompute their keys.
+func NewCachedKey(slice interface{},
+	keyOf func(v interface{}) interface{},
+	keyLess func(a, b interface{}) bool,
+	opts ...Option,
+) Set {
+	less := func(s1, s2 interface{}) bool {
+		return keyLess(keyOf(s1), keyOf(s2))
+	}
+	equal := func(s1, s2 interface{}) bool {
+		k1, k2 := keyOf(s1), keyOf(s2)
+		return !keyLess(k1, k2) && !keyLess(k2, k1)
+	}
+	return NewWithOptions(slice, less, equal, append(opts, func(o *options) {
+		o.keyOf = keyOf
+		o.keyLess = keyLess
+		o.keyEqual = true
+	})...)
+}
+
+// NewMapSet builds a set of maps, or other elements without a natural
+// order, ordered and deduplicated by their canonical string, such as JSON
+// with sorted keys.
+func NewMapSet(arr interface{}, canonical func(v interface{}) string) Set {
+	return NewCachedKey(arr,
+		func(v interface{}) interface{} { return canonical(v) },
+		func(a, b interface{}) bool { return a.(string) < b.(string) },
+	)
+}
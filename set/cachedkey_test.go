@@ -0,0 +1,116 @@
+package set_test
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+// versionKey parses "major.minor" into a comparable number.
+func versionKey(calls *int) func(v interface{}) interface{} {
+	return func(v interface{}) interface{} {
+		*calls++
+		parts := strings.SplitN(v.(string), ".", 2)
+		major, _ := strconv.Atoi(parts[0])
+		minor, _ := strconv.Atoi(parts[1])
+		return major*1000 + minor
+	}
+}
+
+func keyLess(a, b interface{}) bool { return a.(int) < b.(int) }
+
+func TestCachedKey(t *testing.T) {
+	calls := 0
+	s := set.NewCachedKey([]string{"1.10", "1.9", "2.0", "1.09"}, versionKey(&calls), keyLess)
+	if !reflect.DeepEqual(s.Slice(), []string{"1.9", "1.10", "2.0"}) {
+		t.Fatal(s.Slice())
+	}
+	if !s.Has("1.10", 0) || s.Has("1.11", 0) {
+		t.Fatal(s.Slice())
+	}
+	s.Insert("1.11", "0.5")
+	s.Erase("1.9")
+	clone := s.Clone()
+	clone.Erase([]string{"0.5", "2.0"})
+	if !reflect.DeepEqual(s.Slice(), []string{"0.5", "1.10", "1.11", "2.0"}) {
+		t.Fatal(s.Slice())
+	}
+	if !reflect.DeepEqual(clone.Slice(), []string{"1.10", "1.11"}) || !clone.Has("1.11", 0) {
+		t.Fatal(clone.Slice())
+	}
+	s.Slice().([]string)[0] = "3.0"
+	s.ReSort()
+	if !reflect.DeepEqual(s.Slice(), []string{"1.10", "1.11", "2.0", "3.0"}) || !s.Has("3.0", 0) {
+		t.Fatal(s.Slice())
+	}
+	if ins := s.Intersection(clone); !ins.Equal([]string{"1.10", "1.11"}) {
+		t.Fatal(ins.Slice())
+	}
+	// one key per element inserted and per value searched for
+	calls = 0
+	s = set.NewCachedKey([]string{"1.2", "1.1"}, versionKey(&calls), keyLess)
+	s.Replace("1.1")
+	if !s.Has("1.2", 0) || s.Erase("1.3") != 0 || calls != 6 {
+		t.Fatal(calls)
+	}
+}
+
+func BenchmarkCachedKey(b *testing.B) {
+	versions := make([]string, 1000)
+	for i := range versions {
+		versions[i] = strconv.Itoa(rand.Intn(10)) + "." + strconv.Itoa(rand.Intn(1000))
+	}
+	b.Run("cached", func(b *testing.B) {
+		calls := 0
+		for i := 0; i < b.N; i++ {
+			s := set.NewCachedKey([]string{}, versionKey(&calls), keyLess)
+			for _, v := range versions {
+				s.Insert(v)
+			}
+			for _, v := range versions {
+				s.Has(v, 0)
+				s.Replace(v)
+			}
+		}
+		b.ReportMetric(float64(calls)/float64(b.N), "keys/op")
+	})
+	b.Run("comparator", func(b *testing.B) {
+		calls := 0
+		key := versionKey(&calls)
+		for i := 0; i < b.N; i++ {
+			s := set.New([]string{}, func(s1, s2 interface{}) bool {
+				return keyLess(key(s1), key(s2))
+			})
+			for _, v := range versions {
+				s.Insert(v)
+			}
+			for _, v := range versions {
+				s.Has(v, 0)
+				s.Replace(v)
+			}
+		}
+		b.ReportMetric(float64(calls)/float64(b.N), "keys/op")
+	})
+}
+
+func TestMapSet(t *testing.T) {
+	canonical := func(v interface{}) string {
+		data, _ := json.Marshal(v) // encoding/json sorts map keys
+		return string(data)
+	}
+	a := map[string]string{"host": "a", "port": "80"}
+	b := map[string]string{"port": "80"}
+	b["host"] = "a"
+	s := set.NewMapSet([]map[string]string{a, {"host": "b"}}, canonical)
+	if s.Insert(b) != 0 || s.Len() != 2 || !s.Has(map[string]string{"port": "80", "host": "a"}, 0) {
+		t.Fatal(s.Slice())
+	}
+	if s.Insert(map[string]string{"host": "a"}) != 1 || s.Erase(b) != 1 || s.Len() != 2 {
+		t.Fatal(s.Slice())
+	}
+}
@@ -0,0 +1,57 @@
+package set
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+)
+
+// ErrCorruptDelta ...
+var ErrCorruptDelta = errors.New("set: corrupt delta encoding")
+
+// MarshalIntsDelta encodes a set of int as its first element followed by
+// the differences between successive elements, all as varints. Dense sets
+// take about a byte per element.
+func MarshalIntsDelta(s Set) ([]byte, error) {
+	arr, ok := s.Slice().([]int)
+	if !ok {
+		return nil, ErrNotInts
+	}
+	buf := make([]byte, 0, len(arr)+binary.MaxVarintLen64)
+	tmp := make([]byte, binary.MaxVarintLen64)
+	for i, v := range arr {
+		if i == 0 {
+			buf = append(buf, tmp[:binary.PutVarint(tmp, int64(v))]...)
+			continue
+		}
+		buf = append(buf, tmp[:binary.PutUvarint(tmp, uint64(v-arr[i-1]))]...)
+	}
+	return buf, nil
+}
+
+// UnmarshalIntsDelta decodes a set of int encoded by MarshalIntsDelta.
+func UnmarshalIntsDelta(data []byte) (Set, error) {
+	arr := []int{}
+	for len(data) > 0 {
+		var n int
+		if len(arr) == 0 {
+			var v int64
+			v, n = binary.Varint(data)
+			arr = append(arr, int(v))
+		} else {
+			var d uint64
+			d, n = binary.Uvarint(data)
+			if d == 0 {
+				n = 0
+			}
+			arr = append(arr, arr[len(arr)-1]+int(d))
+		}
+		if n <= 0 {
+			return nil, ErrCorruptDelta
+		}
+		data = data[n:]
+	}
+	s := Ints([]int{}).(*set)
+	s.adopt(reflect.ValueOf(arr))
+	return s, nil
+}
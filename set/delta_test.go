@@ -0,0 +1,39 @@
+package set_test
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestIntsDelta(t *testing.T) {
+	arr := make([]int, 10000)
+	for i := range arr {
+		arr[i] = -500 + i*3
+	}
+	s := set.Ints(arr)
+	data, err := set.MarshalIntsDelta(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw := len(arr) * int(unsafe.Sizeof(arr[0])); len(data)*4 > raw {
+		t.Fatal(len(data), raw)
+	}
+	s2, err := set.UnmarshalIntsDelta(data)
+	if err != nil || !s2.Equal(s.Slice()) {
+		t.Fatal(err, s2)
+	}
+	if empty, err := set.UnmarshalIntsDelta(nil); err != nil || empty.Len() != 0 {
+		t.Fatal(err, empty)
+	}
+	if _, err := set.UnmarshalIntsDelta([]byte{2, 0}); err != set.ErrCorruptDelta {
+		t.Fatal(err)
+	}
+	if _, err := set.UnmarshalIntsDelta([]byte{2, 0x80}); err != set.ErrCorruptDelta {
+		t.Fatal(err)
+	}
+	if _, err := set.MarshalIntsDelta(set.Strings([]string{"a"})); err != set.ErrNotInts {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,66 @@
+//go:build go1.18
+// +build go1.18
+
+package set_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+// FuzzSetInvariant applies the ops encoded by data to a set and a reference
+// map: each op is a header byte, its low bit choosing insert or erase and
+// the rest a batch length, followed by that many small elements. A batch of
+// one goes through InsertOne or EraseOne, longer ones through the slice paths.
+func FuzzSetInvariant(f *testing.F) {
+	f.Add([]byte{6, 1, 2, 3, 7, 2, 3, 4})
+	f.Add([]byte{8, 5, 5, 1, 9, 4, 9, 0, 5, 5, 1})
+	f.Add([]byte{10, 9, 8, 7, 6, 5, 11, 9, 7, 5, 3, 1, 2, 4})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s := set.Ints(nil)
+		ref := map[int]bool{}
+		for len(data) > 0 {
+			erase, n := data[0]&1 == 1, int(data[0]>>1)
+			data = data[1:]
+			if n > len(data) {
+				n = len(data)
+			}
+			batch := make([]int, n)
+			for i, b := range data[:n] {
+				batch[i] = int(b % 32)
+			}
+			data = data[n:]
+			for _, v := range batch {
+				ref[v] = !erase
+			}
+			switch {
+			case n == 1 && erase:
+				s.Erase(batch[0])
+			case n == 1:
+				s.Insert(batch[0])
+			case erase:
+				s.Erase(batch)
+			default:
+				s.Insert(batch)
+			}
+			want := []int{}
+			for v, ok := range ref {
+				if ok {
+					want = append(want, v)
+				}
+			}
+			sort.Ints(want)
+			got, _ := s.Slice().([]int)
+			if !s.IsSorted() || len(got) != len(want) {
+				t.Fatal(got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] || !s.Has(want[i], 0) {
+					t.Fatal(got, want)
+				}
+			}
+		}
+	})
+}
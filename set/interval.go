@@ -0,0 +1,42 @@
+package set
+
+// Interval is a closed range [Start, End].
+type Interval struct {
+	Start, End int
+}
+
+// IntervalSet is a set of intervals ordered by start, then end.
+type IntervalSet interface {
+	Set
+	Coalesce()
+}
+
+type intervalSet struct {
+	*set
+}
+
+// NewIntervals returns an IntervalSet of arr.
+func NewIntervals(arr []Interval) IntervalSet {
+	return &intervalSet{New(arr, func(s1, s2 interface{}) bool {
+		a, b := s1.(Interval), s2.(Interval)
+		return a.Start < b.Start || a.Start == b.Start && a.End < b.End
+	}).(*set)}
+}
+
+// Coalesce merges overlapping intervals in place, including those that only
+// share an endpoint.
+func (p *intervalSet) Coalesce() {
+	p.unshare()
+	s, _ := p.Slice().([]Interval)
+	last := -1
+	p.compact(0, len(s), func(i int) bool {
+		if last >= 0 && s[i].Start <= s[last].End {
+			if s[i].End > s[last].End {
+				s[last].End = s[i].End
+			}
+			return false
+		}
+		last = i
+		return true
+	})
+}
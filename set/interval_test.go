@@ -0,0 +1,20 @@
+package set_test
+
+import (
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestCoalesce(t *testing.T) {
+	s := set.NewIntervals([]set.Interval{{7, 8}, {2, 5}, {1, 3}})
+	s.Coalesce()
+	if !s.Equal([]set.Interval{{1, 5}, {7, 8}}) {
+		t.Fatal(s.Slice())
+	}
+	s.Insert(set.Interval{5, 6}, set.Interval{8, 8}, set.Interval{10, 12}, set.Interval{11, 11})
+	s.Coalesce()
+	if !s.Equal([]set.Interval{{1, 6}, {7, 8}, {10, 12}}) {
+		t.Fatal(s.Slice())
+	}
+}
@@ -0,0 +1,46 @@
+package set
+
+import "sync"
+
+// LoadingSet is a concurrency safe set that loads missing elements.
+type LoadingSet interface {
+	Set
+	GetOrLoad(key interface{}) (interface{}, bool)
+}
+
+type loadingSet struct {
+	Set
+	load func(key interface{}) (interface{}, bool)
+	mu   sync.Mutex
+}
+
+// NewLoading returns an empty LoadingSet filled by load on misses. A nil
+// equal defaults to reflect.DeepEqual.
+func NewLoading(less func(s1, s2 interface{}) bool,
+	equal func(s1, s2 interface{}) bool,
+	load func(key interface{}) (interface{}, bool),
+) LoadingSet {
+	return &loadingSet{
+		Set:  NewSafe(NewWithOptions(nil, less, equal)),
+		load: load,
+	}
+}
+
+// GetOrLoad returns the element equal to key, or loads and inserts it.
+// Loads are serialized so that a key is loaded once.
+func (p *loadingSet) GetOrLoad(key interface{}) (interface{}, bool) {
+	if v, ok := p.Get(key); ok {
+		return v, true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if v, ok := p.Get(key); ok {
+		return v, true
+	}
+	v, ok := p.load(key)
+	if !ok {
+		return nil, false
+	}
+	p.InsertIfAbsent(v)
+	return v, true
+}
@@ -0,0 +1,51 @@
+package set_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestLoading(t *testing.T) {
+	type user struct {
+		ID   int
+		Name string
+	}
+	var mu sync.Mutex
+	loads := map[int]int{}
+	s := set.NewLoading(
+		func(s1, s2 interface{}) bool { return s1.(user).ID < s2.(user).ID },
+		func(s1, s2 interface{}) bool { return s1.(user).ID == s2.(user).ID },
+		func(key interface{}) (interface{}, bool) {
+			id := key.(user).ID
+			mu.Lock()
+			loads[id]++
+			mu.Unlock()
+			return user{id, "loaded"}, id > 0
+		},
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, ok := s.GetOrLoad(user{ID: 1}); !ok || v.(user).Name != "loaded" {
+				t.Error(v, ok)
+			}
+		}()
+	}
+	wg.Wait()
+	if loads[1] != 1 || s.Len() != 1 {
+		t.Fatal(loads, s.Slice())
+	}
+	if _, ok := s.GetOrLoad(user{ID: -1}); ok || s.Len() != 1 {
+		t.Fatal(s.Slice())
+	}
+	if v, ok := s.Get(user{ID: 1}); !ok || v.(user).Name != "loaded" {
+		t.Fatal(v, ok)
+	}
+	if s.InsertIfAbsent(user{1, "other"}) || !s.InsertIfAbsent(user{2, "other"}) {
+		t.Fatal(s.Slice())
+	}
+}
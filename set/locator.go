@@ -0,0 +1,49 @@
+package set
+
+import "sync"
+
+// Locator follows an element by value rather than by index, so it stays
+// right when writes move the element or erase it.
+type Locator interface {
+	// Value returns the stored element, or false once it is absent.
+	Value() (interface{}, bool)
+}
+
+// setLocator searches the set on every call, as a plain set keeps no
+// count of its writes.
+type setLocator struct {
+	s *set
+	v interface{}
+}
+
+func (p setLocator) Value() (interface{}, bool) {
+	return p.s.Get(p.v)
+}
+
+// safeLocator caches the index of the element and searches again only
+// once the set has been written to. It is safe for concurrent use.
+type safeLocator struct {
+	s       *safeSet
+	v       interface{}
+	mu      sync.Mutex
+	valid   bool
+	version uint64
+	index   int
+	found   bool
+}
+
+func (p *safeLocator) Value() (interface{}, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.s.RLock()
+	defer p.s.RUnlock()
+	if !p.valid || p.version != p.s.version {
+		p.index = p.s.set.Search(p.v, 0)
+		p.found = p.index < p.s.set.Len() && p.s.set.Has(p.v, p.index)
+		p.valid, p.version = true, p.s.version
+	}
+	if !p.found {
+		return nil, false
+	}
+	return valueOf(p.s.set).Index(p.index).Interface(), true
+}
@@ -0,0 +1,56 @@
+package set_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestLocator(t *testing.T) {
+	byID := func(s1, s2 interface{}) bool { return s1.(testStruct).ID < s2.(testStruct).ID }
+	sameID := func(s1, s2 interface{}) bool { return s1.(testStruct).ID == s2.(testStruct).ID }
+	plain := set.NewWithOptions([]testStruct{{1, 1}, {5, 5}}, byID, sameID, set.WithOnConflict(set.OnConflictOverwrite))
+	l := plain.LocatorFor(testStruct{ID: 5})
+	plain.Insert(testStruct{3, 3}, testStruct{5, 50})
+	if v, ok := l.Value(); !ok || v != (testStruct{5, 50}) {
+		t.Fatal(v, ok)
+	}
+	plain.Erase(testStruct{ID: 5})
+	if v, ok := l.Value(); ok {
+		t.Fatal(v)
+	}
+
+	s := set.NewSafe(set.New([]testStruct{{500, 0}}, byID, sameID))
+	l = s.LocatorFor(testStruct{ID: 500})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// shift the element around, keeping it until the end
+		for i := 0; i < 1000; i++ {
+			s.Insert(testStruct{i, i})
+			if i%3 == 0 {
+				s.Erase(testStruct{ID: i / 2})
+			}
+		}
+		s.Erase(testStruct{ID: 500})
+	}()
+	for i := 0; i < 2000; i++ {
+		v, ok := l.Value()
+		if ok && v.(testStruct).ID != 500 {
+			t.Fatal(v)
+		}
+		if !ok {
+			// erased only at the end
+			wg.Wait()
+			if s.Has(testStruct{ID: 500}, 0) {
+				t.Fatal(s.Len())
+			}
+		}
+	}
+	wg.Wait()
+	if v, ok := l.Value(); ok {
+		t.Fatal(v)
+	}
+}
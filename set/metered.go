@@ -0,0 +1,195 @@
+package set
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// MeteredSet is a set counting its inserts and erases, for export to a
+// metrics system.
+type MeteredSet interface {
+	Set
+	Collect() map[string]float64
+}
+
+type meteredSet struct {
+	// first for 64-bit alignment of the atomic counters
+	inserts, erases uint64
+	Set
+	name string
+}
+
+// NewMetered wraps s, counting the elements added and removed by each
+// method changing it. Those not reporting what they changed, such as
+// Update, Reset and ReSort, are counted from the change in Len, which
+// concurrent writes through other methods can skew. The counters are safe
+// for concurrent use if s is.
+func NewMetered(s Set, name string) MeteredSet {
+	return &meteredSet{
+		Set:  s,
+		name: name,
+	}
+}
+
+// Collect returns the current metrics keyed by name prefixed with the name
+// of the set: the gauge name_size and the counters name_inserts_total and
+// name_erases_total.
+func (p *meteredSet) Collect() map[string]float64 {
+	return map[string]float64{
+		p.name + "_size":          float64(p.Len()),
+		p.name + "_inserts_total": float64(atomic.LoadUint64(&p.inserts)),
+		p.name + "_erases_total":  float64(atomic.LoadUint64(&p.erases)),
+	}
+}
+
+func (p *meteredSet) Insert(v ...interface{}) int {
+	n := p.Set.Insert(v...)
+	atomic.AddUint64(&p.inserts, uint64(n))
+	return n
+}
+
+func (p *meteredSet) Replace(v ...interface{}) int {
+	n := p.Set.Replace(v...)
+	atomic.AddUint64(&p.inserts, uint64(n))
+	return n
+}
+
+func (p *meteredSet) InsertIfAbsent(v interface{}) bool {
+	ok := p.Set.InsertIfAbsent(v)
+	if ok {
+		atomic.AddUint64(&p.inserts, 1)
+	}
+	return ok
+}
+
+func (p *meteredSet) InsertE(v ...interface{}) (int, error) {
+	n, err := p.Set.InsertE(v...)
+	atomic.AddUint64(&p.inserts, uint64(n))
+	return n, err
+}
+
+func (p *meteredSet) Erase(v ...interface{}) int {
+	n := p.Set.Erase(v...)
+	atomic.AddUint64(&p.erases, uint64(n))
+	return n
+}
+
+func (p *meteredSet) Toggle(v interface{}) (nowPresent bool) {
+	nowPresent = p.Set.Toggle(v)
+	if nowPresent {
+		atomic.AddUint64(&p.inserts, 1)
+	} else {
+		atomic.AddUint64(&p.erases, 1)
+	}
+	return
+}
+
+// count adds a change in length to the inserts if positive, else to the
+// erases.
+func (p *meteredSet) count(delta int) {
+	if delta > 0 {
+		atomic.AddUint64(&p.inserts, uint64(delta))
+	} else if delta < 0 {
+		atomic.AddUint64(&p.erases, uint64(-delta))
+	}
+}
+
+func (p *meteredSet) Dedup() int {
+	n := p.Set.Dedup()
+	atomic.AddUint64(&p.erases, uint64(n))
+	return n
+}
+
+func (p *meteredSet) DrainRange(lo, hi interface{}) interface{} {
+	drained := p.Set.DrainRange(lo, hi)
+	atomic.AddUint64(&p.erases, uint64(lenOf(reflect.ValueOf(drained))))
+	return drained
+}
+
+func (p *meteredSet) FlushBelow(v interface{}) interface{} {
+	flushed := p.Set.FlushBelow(v)
+	atomic.AddUint64(&p.erases, uint64(lenOf(reflect.ValueOf(flushed))))
+	return flushed
+}
+
+func (p *meteredSet) SplitAt(pivot interface{}) Set {
+	greaterOrEqual := p.Set.SplitAt(pivot)
+	atomic.AddUint64(&p.erases, uint64(greaterOrEqual.Len()))
+	return greaterOrEqual
+}
+
+func (p *meteredSet) EraseExact(v interface{}, exactEqual func(a, b interface{}) bool) bool {
+	ok := p.Set.EraseExact(v, exactEqual)
+	if ok {
+		atomic.AddUint64(&p.erases, 1)
+	}
+	return ok
+}
+
+func (p *meteredSet) EraseRangeIf(lo, hi interface{}, pred func(v interface{}) bool) int {
+	n := p.Set.EraseRangeIf(lo, hi, pred)
+	atomic.AddUint64(&p.erases, uint64(n))
+	return n
+}
+
+func (p *meteredSet) CoalesceBy(same func(a, b interface{}) bool, merge func(a, b interface{}) interface{}) int {
+	n := p.Set.CoalesceBy(same, merge)
+	atomic.AddUint64(&p.erases, uint64(n))
+	return n
+}
+
+func (p *meteredSet) BatchInsertRCU(slice interface{}) int {
+	n := p.Set.BatchInsertRCU(slice)
+	atomic.AddUint64(&p.inserts, uint64(n))
+	return n
+}
+
+func (p *meteredSet) ApplyDiff(added, removed interface{}) (net int) {
+	net = p.Set.ApplyDiff(added, removed)
+	p.count(net)
+	return
+}
+
+func (p *meteredSet) Update(fn func(s Set) error) error {
+	before := p.Len()
+	err := p.Set.Update(fn)
+	p.count(p.Len() - before)
+	return err
+}
+
+func (p *meteredSet) Reset(slice interface{}, less, equal func(s1, s2 interface{}) bool) {
+	atomic.AddUint64(&p.erases, uint64(p.Len()))
+	p.Set.Reset(slice, less, equal)
+	atomic.AddUint64(&p.inserts, uint64(p.Len()))
+}
+
+func (p *meteredSet) ReSort() {
+	before := p.Len()
+	p.Set.ReSort()
+	p.count(p.Len() - before)
+}
+
+func (p *meteredSet) RefreshOrder() {
+	before := p.Len()
+	p.Set.RefreshOrder()
+	p.count(p.Len() - before)
+}
+
+func (p *meteredSet) SetComparator(less, equal func(s1, s2 interface{}) bool) {
+	before := p.Len()
+	p.Set.SetComparator(less, equal)
+	p.count(p.Len() - before)
+}
+
+func (p *meteredSet) Reindex(keyOf func(s Set, v interface{}) float64) {
+	before := p.Len()
+	p.Set.Reindex(keyOf)
+	p.count(p.Len() - before)
+}
+
+func (p *meteredSet) Repair() (fixed int, err error) {
+	before := p.Len()
+	fixed, err = p.Set.Repair()
+	p.count(p.Len() - before)
+	return
+}
@@ -0,0 +1,76 @@
+package set_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestMetered(t *testing.T) {
+	s := set.NewMetered(set.Ints([]int{1, 2}), "ids")
+	s.Insert(2, 3, []int{4, 5})
+	s.InsertIfAbsent(6)
+	s.InsertIfAbsent(6)
+	s.Erase(1, 9)
+	s.Toggle(1)
+	s.Toggle(5)
+	want := map[string]float64{"ids_size": 5, "ids_inserts_total": 5, "ids_erases_total": 2}
+	got := s.Collect()
+	if len(got) != len(want) {
+		t.Fatal(got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatal(got)
+		}
+	}
+	if u := set.AtLeastK(1, s, set.Ints([]int{7})); u.Len() != 6 {
+		t.Fatal(u.Slice())
+	}
+
+	safe := set.NewMetered(set.NewSafe(set.Ints(nil)), "safe")
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				safe.Insert(g*100 + i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if m := safe.Collect(); m["safe_size"] != 400 || m["safe_inserts_total"] != 400 {
+		t.Fatal(m)
+	}
+}
+
+func TestMeteredMutations(t *testing.T) {
+	s := set.NewMetered(set.Ints([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}), "ids")
+	s.ApplyDiff([]int{11, 12}, []int{1})
+	s.DrainRange(2, 4)
+	s.FlushBelow(5)
+	s.EraseExact(5, func(a, b interface{}) bool { return a == b })
+	s.EraseRangeIf(6, 9, func(v interface{}) bool { return v.(int)%2 == 0 })
+	s.SplitAt(12)
+	s.BatchInsertRCU([]int{20, 21})
+	s.Update(func(c set.Set) error {
+		c.Insert(30, 31, 32)
+		c.Erase(7)
+		return nil
+	})
+	s.Slice().([]int)[1] = 9
+	if s.Dedup() != 1 {
+		t.Fatal(s.Slice())
+	}
+	s.Reset([]int{1, 2}, intLess, nil)
+	s.Insert(3)
+	m := s.Collect()
+	if m["ids_size"] != 3 || m["ids_inserts_total"]-m["ids_erases_total"] != float64(s.Len()-10) {
+		t.Fatal(m)
+	}
+	if m["ids_inserts_total"] != 8 || m["ids_erases_total"] != 15 {
+		t.Fatal(m)
+	}
+}
@@ -0,0 +1,95 @@
+package set
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrLengthMismatch ...
+var ErrLengthMismatch = errors.New("set: sets differ in length")
+
+// inner returns the plain set holding the comparators of s, or nil. Types
+// embedding *set, such as bounded and interval sets, get plain from it.
+func inner(s Set) *set {
+	switch p := s.(type) {
+	case *safeSet:
+		return inner(p.set)
+	case *loadingSet:
+		return inner(p.Set)
+	case *meteredSet:
+		return inner(p.Set)
+	case interface{ plain() *set }:
+		return p.plain()
+	}
+	return nil
+}
+
+func (p *set) plain() *set {
+	return p
+}
+
+// AtLeastK returns the elements present in at least k of sets, via one
+// k-way merge. k = 1 is the union, k = len(sets) the intersection. The
+// result has the comparators of the first set, and is empty if no set has a
+// backing slice. It is nil for no sets, or a first set not of this package.
+func AtLeastK(k int, sets ...Set) Set {
+	if len(sets) == 0 {
+		return nil
+	}
+	first := inner(sets[0])
+	if first == nil {
+		return nil
+	}
+	values := make([]reflect.Value, len(sets))
+	heads := make([]int, len(sets))
+	var typ reflect.Type
+	for i, s := range sets {
+		values[i] = valueOf(s)
+		if typ == nil && values[i].IsValid() {
+			typ = values[i].Type()
+		}
+	}
+	if typ == nil {
+		return sets[0].Zero()
+	}
+	dst := reflect.Zero(typ)
+	for {
+		var min reflect.Value
+		for i, rv := range values {
+			if heads[i] < lenOf(rv) {
+				v := rv.Index(heads[i])
+				if !min.IsValid() || first.less(v.Interface(), min.Interface()) {
+					min = v
+				}
+			}
+		}
+		if !min.IsValid() {
+			break
+		}
+		count := 0
+		for i, rv := range values {
+			if heads[i] < lenOf(rv) && first.equal(rv.Index(heads[i]).Interface(), min.Interface()) {
+				count++
+				heads[i]++
+			}
+		}
+		if count >= k {
+			dst = reflect.Append(dst, min)
+		}
+	}
+	return sets[0].New(dst.Interface(), true)
+}
+
+// Zip pairs the i-th elements of a and b, returning a []interface{} of
+// combine(a[i], b[i]).
+func Zip(a, b Set, combine func(x, y interface{}) interface{}) (interface{}, error) {
+	ra, rb := valueOf(a), valueOf(b)
+	if lenOf(ra) != lenOf(rb) {
+		return nil, ErrLengthMismatch
+	}
+	dst := make([]interface{}, lenOf(ra))
+	for i := range dst {
+		dst[i] = combine(ra.Index(i).Interface(), rb.Index(i).Interface())
+	}
+	return dst, nil
+}
@@ -0,0 +1,59 @@
+package set_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestAtLeastK(t *testing.T) {
+	sets := []set.Set{
+		set.Ints([]int{1, 2, 3, 5}),
+		set.Ints([]int{2, 3, 4}),
+		set.NewSafe(set.Ints([]int{1, 3, 4, 6})),
+		set.Ints([]int{1, 4, 7}),
+	}
+	if s := set.AtLeastK(3, sets...); !s.Equal([]int{1, 3, 4}) {
+		t.Fatal(s.Slice())
+	}
+	if s := set.AtLeastK(1, sets...); !s.Equal([]int{1, 2, 3, 4, 5, 6, 7}) {
+		t.Fatal(s.Slice())
+	}
+	if s := set.AtLeastK(4, sets...); s.Len() != 0 {
+		t.Fatal(s.Slice())
+	}
+	if set.AtLeastK(1) != nil {
+		t.Fatal("no sets")
+	}
+	top := set.NewTopN(3, intLess)
+	top.Insert([]int{1, 2, 3, 4})
+	if s := set.AtLeastK(2, top, sets[0]); !s.Equal([]int{2, 3}) {
+		t.Fatal(s.Slice())
+	}
+	ivs := set.NewIntervals([]set.Interval{{1, 2}, {3, 4}})
+	if s := set.AtLeastK(1, ivs); s.Len() != 2 {
+		t.Fatal(s.Slice())
+	}
+	empty := set.New(nil, intLess)
+	if s := set.AtLeastK(1, empty, sets[1]); !s.Equal([]int{2, 3, 4}) {
+		t.Fatal(s.Slice())
+	}
+	if s := set.AtLeastK(1, empty, set.NewSafe(set.New(nil, intLess))); s == nil || s.Len() != 0 {
+		t.Fatal(s)
+	}
+}
+
+func TestZip(t *testing.T) {
+	add := func(x, y interface{}) interface{} { return x.(int) + y.(int) }
+	zipped, err := set.Zip(set.Ints([]int{3, 1, 2}), set.Ints([]int{10, 30, 20}), add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(zipped, []interface{}{11, 22, 33}) {
+		t.Fatal(zipped)
+	}
+	if _, err = set.Zip(set.Ints([]int{1}), set.Ints([]int{1, 2}), add); err != set.ErrLengthMismatch {
+		t.Fatal(err)
+	}
+}
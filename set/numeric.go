@@ -0,0 +1,94 @@
+package set
+
+import "reflect"
+
+// isNumeric reports whether values of kind k convert to float64.
+func isNumeric(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// toFloat converts a numeric value to float64.
+func toFloat(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
+
+// addNumeric returns v+d in the type of v, for v and d of numeric kinds.
+func addNumeric(v, d reflect.Value) reflect.Value {
+	sum := reflect.New(v.Type()).Elem()
+	d = d.Convert(v.Type())
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sum.SetInt(v.Int() + d.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		sum.SetUint(v.Uint() + d.Uint())
+	default:
+		sum.SetFloat(v.Float() + d.Float())
+	}
+	return sum
+}
+
+// intAt returns a func reading the elements of rv as int, or nil unless rv
+// is a slice of an integer kind.
+func intAt(rv reflect.Value) func(i int) int {
+	if !rv.IsValid() {
+		return nil
+	}
+	switch rv.Type().Elem().Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(i int) int { return int(rv.Index(i).Int()) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(i int) int { return int(rv.Index(i).Uint()) }
+	}
+	return nil
+}
+
+// convertExact converts v to typ, and reports whether the conversion kept
+// the value, converting back to it with the same sign.
+func convertExact(v reflect.Value, typ reflect.Type) (reflect.Value, bool) {
+	c := v.Convert(typ)
+	if c.Convert(v.Type()).Interface() != v.Interface() {
+		return c, false
+	}
+	return c, (toFloat(c) < 0) == (toFloat(v) < 0)
+}
+
+// coercedComparators wraps the comparators of a set built
+// WithNumericCoercion, so that an inexact equals no element, and orders
+// against elements by numeric value. A nil equal is left for the default.
+func coercedComparators(less, equal func(s1, s2 interface{}) bool) (func(s1, s2 interface{}) bool, func(s1, s2 interface{}) bool) {
+	if equal != nil {
+		eq := equal
+		equal = func(s1, s2 interface{}) bool {
+			if isInexact(s1) || isInexact(s2) {
+				return false
+			}
+			return eq(s1, s2)
+		}
+	}
+	return func(s1, s2 interface{}) bool {
+		if isInexact(s1) || isInexact(s2) {
+			return numericOf(s1) < numericOf(s2)
+		}
+		return less(s1, s2)
+	}, equal
+}
+
+// numericOf returns the value of an element or inexact as float64.
+func numericOf(v interface{}) float64 {
+	if x, ok := v.(inexact); ok {
+		v = x.v
+	}
+	return toFloat(reflect.ValueOf(v))
+}
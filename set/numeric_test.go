@@ -0,0 +1,23 @@
+package set_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestGaps(t *testing.T) {
+	if gaps := set.Ints([]int{10, 1, 5, 2}).Gaps(); !reflect.DeepEqual(gaps, []float64{1, 3, 5}) {
+		t.Fatal(gaps)
+	}
+	if gaps := set.Float64s([]float64{0.5, 2}).Gaps(); !reflect.DeepEqual(gaps, []float64{1.5}) {
+		t.Fatal(gaps)
+	}
+	if gaps := set.Uints([]uint{7}).Gaps(); len(gaps) != 0 {
+		t.Fatal(gaps)
+	}
+	if gaps := set.Strings([]string{"a", "b"}).Gaps(); gaps != nil {
+		t.Fatal(gaps)
+	}
+}
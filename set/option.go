@@ -0,0 +1,200 @@
+package set
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Option configures a set built by NewWithOptions.
+type Option func(*options)
+
+const defaultShrinkFactor = 4
+
+type options struct {
+	trackOrder   bool
+	shrinkFactor int
+	name         string
+	merge        func(existing, incoming interface{}) interface{}
+	deref        bool
+	growth       float64
+	coerce       bool
+	keyOf        func(v interface{}) interface{}
+	keyLess      func(a, b interface{}) bool
+	keyEqual     bool
+	immutable    bool
+	conflict     OnConflict
+	sortFunc     func(slice interface{}, less func(i, j int) bool)
+	guard        func(s Set, v interface{}) error
+	min, max     interface{}
+	unkeyed      func(s1, s2 interface{}) bool
+}
+
+// TrackInsertionOrder records the first-insertion order of each element,
+// available through FirstSeenOrder.
+func TrackInsertionOrder() Option {
+	return func(o *options) {
+		o.trackOrder = true
+	}
+}
+
+// WithShrinkFactor makes erasing reallocate the backing slice once its
+// length falls below 1/factor of its capacity. The default factor is 4,
+// a factor <= 0 never shrinks.
+func WithShrinkFactor(factor int) Option {
+	return func(o *options) {
+		o.shrinkFactor = factor
+	}
+}
+
+// WithName tags the comparator with name. Intersection, UnionMerge and
+// UnionReportConflicts between two sets whose comparators are named
+// differently panic with a *ComparatorError; other methods taking a Set do
+// not check, and callers may use CheckComparator.
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// ComparatorError reports set algebra between incompatibly ordered sets.
+type ComparatorError struct {
+	Left, Right string
+}
+
+func (e *ComparatorError) Error() string {
+	return fmt.Sprintf("set: comparator %q is incompatible with %q", e.Left, e.Right)
+}
+
+// CheckComparator returns a *ComparatorError if a and b both have named
+// comparators and the names differ.
+func CheckComparator(a, b Set) error {
+	left, right := a.ComparatorName(), b.ComparatorName()
+	if left == "" || right == "" || left == right {
+		return nil
+	}
+	return &ComparatorError{Left: left, Right: right}
+}
+
+// WithMerge makes Insert store combine(existing, incoming) when an equal
+// element is already present, instead of dropping the incoming one.
+func WithMerge(combine func(existing, incoming interface{}) interface{}) Option {
+	return func(o *options) {
+		o.merge = combine
+		o.conflict = OnConflictMerge
+	}
+}
+
+// OnConflict selects what Insert does with an element equal to one already
+// present.
+type OnConflict int
+
+// Conflict policies
+const (
+	// OnConflictSkip drops the incoming element.
+	OnConflictSkip OnConflict = iota
+	// OnConflictOverwrite stores the incoming element, as Replace does. Of
+	// equal elements in one batch, the last is stored.
+	OnConflictOverwrite
+	// OnConflictError drops the incoming element, and makes InsertE fail
+	// with ErrConflict.
+	OnConflictError
+	// OnConflictMerge stores the combination given to WithMerge, folding
+	// equal elements of one batch in their order.
+	OnConflictMerge
+)
+
+// ErrConflict ...
+var ErrConflict = errors.New("set: equal element already present")
+
+// WithOnConflict sets the conflict policy of Insert, OnConflictSkip by
+// default.
+func WithOnConflict(policy OnConflict) Option {
+	return func(o *options) {
+		o.conflict = policy
+	}
+}
+
+// WithDerefInsert stores the values pointed to instead of pointers, so later
+// changes through the caller's pointers don't reach the set. Elements are of
+// the pointee type, and pointers are dereferenced in queries as well.
+func WithDerefInsert() Option {
+	return func(o *options) {
+		o.deref = true
+	}
+}
+
+// WithGrowthFactor makes the set grow its backing slice by factor f when
+// full, instead of by the runtime's append policy. A smaller factor leaves
+// less slack at the cost of more reallocations. f <= 1 keeps the default.
+func WithGrowthFactor(f float64) Option {
+	return func(o *options) {
+		o.growth = f
+	}
+}
+
+// WithNumericCoercion converts numeric values of another type, such as an
+// untyped int constant queried against a set of int64, to the element type
+// before comparing or inserting them, along with the bounds of WithBounds.
+// The element type is that of the slice given to NewWithOptions, so give a
+// typed one such as []int64(nil): a set built from a nil slice takes the
+// type of the first value inserted instead, and leaves its bounds as given.
+func WithNumericCoercion() Option {
+	return func(o *options) {
+		o.coerce = true
+	}
+}
+
+// WithImmutabilityCheck keeps a copy of each element as inserted, so that
+// Validate and IsSorted detect elements changed through Slice in a way that
+// makes them unequal to their copy, such as a mutated key field. It costs a
+// copy of every element and is meant for debugging. ReSort takes new copies.
+func WithImmutabilityCheck() Option {
+	return func(o *options) {
+		o.keyOf = func(v interface{}) interface{} { return v }
+		o.keyLess = nil
+		o.immutable = true
+	}
+}
+
+// ErrUnsorted ...
+var ErrUnsorted = errors.New("set: elements out of order")
+
+// MutationError reports an element changed after it was inserted.
+type MutationError struct {
+	Index    int
+	Was, Now interface{}
+}
+
+func (e *MutationError) Error() string {
+	return fmt.Sprintf("set: element %d changed from %v to %v", e.Index, e.Was, e.Now)
+}
+
+// WithSortFunc sorts the unsorted batches given to Insert and the other
+// batch operations by fn instead of sort.Slice, such as an insertion sort
+// for batches which are nearly sorted. fn must be stable for the batch
+// order of OnConflictOverwrite and OnConflictMerge to hold.
+func WithSortFunc(fn func(slice interface{}, less func(i, j int) bool)) Option {
+	return func(o *options) {
+		o.sortFunc = fn
+	}
+}
+
+// WithInsertGuard calls guard with the set before inserting each element,
+// and drops the element if guard fails, InsertE returning the error. guard
+// must not change the set.
+func WithInsertGuard(guard func(s Set, v interface{}) error) Option {
+	return func(o *options) {
+		o.guard = guard
+	}
+}
+
+// ErrOutOfBounds ...
+var ErrOutOfBounds = errors.New("set: element out of bounds")
+
+// WithBounds makes Insert drop the elements less than min or greater than
+// max, InsertE failing with ErrOutOfBounds. A nil bound is open.
+func WithBounds(min, max interface{}) Option {
+	return func(o *options) {
+		o.min, o.max = min, max
+	}
+}
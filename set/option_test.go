@@ -0,0 +1,454 @@
+package set_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func intLess(s1, s2 interface{}) bool { return s1.(int) < s2.(int) }
+
+func int64Less(s1, s2 interface{}) bool { return s1.(int64) < s2.(int64) }
+
+func TestTrackInsertionOrder(t *testing.T) {
+	s := set.NewWithOptions([]int{5, 3, 5, 1, 3, 4}, intLess, nil, set.TrackInsertionOrder())
+	if !s.Equal([]int{1, 3, 4, 5}) {
+		t.Fatal(s.Slice())
+	}
+	if order := s.FirstSeenOrder(); !reflect.DeepEqual(order, []int{5, 3, 1, 4}) {
+		t.Fatal(order)
+	}
+	s.Insert([]int{2, 4, 0})
+	s.Erase(3)
+	if order := s.FirstSeenOrder(); !reflect.DeepEqual(order, []int{5, 1, 4, 2, 0}) {
+		t.Fatal(order)
+	}
+	clone := s.Clone()
+	clone.Slice().([]int)[0] = 9
+	clone.ReSort()
+	if order := clone.FirstSeenOrder(); !reflect.DeepEqual(order, []int{5, 1, 4, 2, 9}) {
+		t.Fatal(order)
+	}
+	if set.Ints([]int{2, 1}).FirstSeenOrder() != nil {
+		t.Fatal("order tracked without option")
+	}
+	r := set.NewWithOptions([]int{}, intLess, nil, set.TrackInsertionOrder())
+	r.Replace([]int{5, 0})
+	r.Replace([]int{7, 5})
+	if order := r.FirstSeenOrder(); !reflect.DeepEqual(order, []int{5, 0, 7}) {
+		t.Fatal(order)
+	}
+}
+
+func TestShrinkFactor(t *testing.T) {
+	arr := make([]int, 1000)
+	for i := range arr {
+		arr[i] = i
+	}
+	s := set.Ints(arr)
+	grown := s.Cap()
+	s.Erase(arr[10:500])
+	if s.Cap() != grown {
+		t.Fatal(s.Cap())
+	}
+	s.Erase(arr[500:])
+	if s.Len() != 10 || s.Cap() >= grown/4 {
+		t.Fatal(s.Len(), s.Cap())
+	}
+	if !s.Equal(arr[:10]) {
+		t.Fatal(s.Slice())
+	}
+
+	arr = arr[:0]
+	for i := 0; i < 1000; i++ {
+		arr = append(arr, i)
+	}
+	s = set.NewWithOptions(arr, intLess, nil, set.WithShrinkFactor(0))
+	grown = s.Cap()
+	s.Erase(arr[10:])
+	if s.Cap() != grown {
+		t.Fatal(s.Cap())
+	}
+}
+
+func TestWithName(t *testing.T) {
+	asc := set.NewWithOptions([]int{1, 2, 3}, intLess, nil, set.WithName("asc"))
+	desc := set.NewWithOptions([]int{1, 2, 3},
+		func(s1, s2 interface{}) bool { return s1.(int) > s2.(int) }, nil, set.WithName("desc"))
+	if asc.ComparatorName() != "asc" || asc.Clone().ComparatorName() != "asc" {
+		t.Fatal(asc.ComparatorName())
+	}
+	if err := set.CheckComparator(asc, set.Ints([]int{2})); err != nil {
+		t.Fatal(err)
+	}
+	err := set.CheckComparator(asc, desc)
+	if _, ok := err.(*set.ComparatorError); !ok {
+		t.Fatal(err)
+	}
+	safe := set.NewSafe(asc)
+	for _, op := range []func(){
+		func() { safe.Intersection(desc) },
+		func() { safe.UnionMerge(desc, func(a, b interface{}) interface{} { return a }) },
+		func() { safe.UnionReportConflicts(desc, func(a, b interface{}) bool { return a == b }) },
+	} {
+		func() {
+			defer func() {
+				r := recover()
+				if e, ok := r.(error); !ok || e.Error() != err.Error() {
+					t.Fatal(r)
+				}
+			}()
+			op()
+			t.Fatal("should panic")
+		}()
+		// the panic left the locks released
+		if safe.Insert(4) != 1 || safe.Erase(4) != 1 {
+			t.Fatal(safe.Slice())
+		}
+	}
+}
+
+func TestWithMerge(t *testing.T) {
+	type counter struct {
+		Key   string
+		Count int
+	}
+	s := set.NewWithOptions([]counter{{"b", 1}, {"a", 1}, {"b", 2}},
+		func(s1, s2 interface{}) bool { return s1.(counter).Key < s2.(counter).Key },
+		func(s1, s2 interface{}) bool { return s1.(counter).Key == s2.(counter).Key },
+		set.WithMerge(func(existing, incoming interface{}) interface{} {
+			c := existing.(counter)
+			c.Count += incoming.(counter).Count
+			return c
+		}),
+	)
+	if n := s.Insert(counter{"a", 4}, []counter{{"c", 1}, {"b", 3}}); n != 1 {
+		t.Fatal(n, s.Slice())
+	}
+	if !reflect.DeepEqual(s.Slice(), []counter{{"a", 5}, {"b", 6}, {"c", 1}}) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestWithDerefInsert(t *testing.T) {
+	type item struct {
+		ID   int
+		Name string
+	}
+	less := func(s1, s2 interface{}) bool { return s1.(item).ID < s2.(item).ID }
+	a, b, c := &item{2, "b"}, &item{1, "a"}, &item{3, "c"}
+	s := set.NewWithOptions([]*item{a, b}, less, nil, set.WithDerefInsert())
+	if s.Insert(c) != 1 {
+		t.Fatal(s.Slice())
+	}
+	a.Name, c.ID = "changed", 0
+	if !reflect.DeepEqual(s.Slice(), []item{{1, "a"}, {2, "b"}, {3, "c"}}) {
+		t.Fatal(s.Slice())
+	}
+	if !s.Has(&item{2, "b"}, 0) || s.Erase(b) != 1 {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestWithGrowthFactor(t *testing.T) {
+	s := set.NewWithOptions([]int{}, intLess, nil, set.WithGrowthFactor(1.5))
+	for i := 0; i < 100; i++ {
+		s.Insert(i)
+		if s.Cap() > s.Len()*3/2+1 {
+			t.Fatal(s.Len(), s.Cap())
+		}
+	}
+	c := s.Cap()
+	s.Reserve(c - s.Len() + 1)
+	if s.Cap() != int(float64(c)*1.5) {
+		t.Fatal(c, s.Cap())
+	}
+	if s.Len() != 100 || !s.Has([]int{0, 50, 99}, 0) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func BenchmarkGrowthFactor(b *testing.B) {
+	for _, f := range []float64{0, 1.25, 1.5, 2} {
+		b.Run(fmt.Sprint("factor-", f), func(b *testing.B) {
+			peak := 0
+			for i := 0; i < b.N; i++ {
+				s := set.NewWithOptions([]int{}, intLess, nil, set.WithGrowthFactor(f))
+				for v := 0; v < 1000000; v++ {
+					s.Insert(v)
+				}
+				peak = s.Cap()
+			}
+			b.ReportMetric(float64(peak), "cap")
+		})
+	}
+}
+
+func TestWithNumericCoercion(t *testing.T) {
+	s := set.NewWithOptions([]int64{3, 1, 2},
+		func(s1, s2 interface{}) bool { return s1.(int64) < s2.(int64) }, nil,
+		set.WithNumericCoercion())
+	if !s.Has(2, 0) || s.Has(42, 0) || s.Search(3, 0) != 2 {
+		t.Fatal(s.Slice())
+	}
+	if s.Insert(42, uint8(7), []int{2, 5}) != 3 {
+		t.Fatal(s.Slice())
+	}
+	if !s.Has([]int{1, 42}, 0) || s.Erase(7.0) != 1 {
+		t.Fatal(s.Slice())
+	}
+	if !reflect.DeepEqual(s.Slice(), []int64{1, 2, 3, 5, 42}) {
+		t.Fatal(s.Slice())
+	}
+	// values the element type cannot hold exactly are absent
+	if s.Has(2.9, 0) || s.Has(uint64(1<<64-1), 0) || s.Erase(3.5) != 0 || s.Has([]float64{1, 2.5}, 0) {
+		t.Fatal(s.Slice())
+	}
+	s.Insert(int64(-1))
+	if s.Has(uint64(1<<64-1), 0) || s.Insert(0.5, []float64{6, 6.5}) != 1 || s.Toggle(7.5) || s.InsertIfAbsent(8.5) {
+		t.Fatal(s.Slice())
+	}
+	if _, err := s.InsertE(9.5); err != set.ErrInexact {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Slice(), []int64{-1, 1, 2, 3, 5, 6, 42}) || !s.Has(6.0, 0) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestNumericCoercionOrder(t *testing.T) {
+	tens := func() set.Set {
+		return set.NewWithOptions([]int64{10, 20, 30}, int64Less, nil, set.WithNumericCoercion())
+	}
+	s := tens()
+	if s.Rank(25.5) != 2 || s.Rank(-0.5) != 0 || s.Rank(30.5) != 3 {
+		t.Fatal(s.Rank(25.5), s.Rank(-0.5), s.Rank(30.5))
+	}
+	if below, above, hasBelow, hasAbove := s.Neighbors(25.5); below != int64(20) || above != int64(30) || !hasBelow || !hasAbove {
+		t.Fatal(below, above)
+	}
+	if ge := s.SplitAt(15.5); !reflect.DeepEqual(ge.Slice(), []int64{20, 30}) || !reflect.DeepEqual(s.Slice(), []int64{10}) {
+		t.Fatal(ge.Slice(), s.Slice())
+	}
+	s = tens()
+	if drained := s.DrainRange(5.5, 25.5); !reflect.DeepEqual(drained, []int64{10, 20}) || !reflect.DeepEqual(s.Slice(), []int64{30}) {
+		t.Fatal(drained, s.Slice())
+	}
+	s = tens()
+	if flushed := s.FlushBelow(25.5); !reflect.DeepEqual(flushed, []int64{10, 20}) || !reflect.DeepEqual(s.Slice(), []int64{30}) {
+		t.Fatal(flushed, s.Slice())
+	}
+}
+
+func TestNumericCoercionType(t *testing.T) {
+	s := set.NewWithOptions([]int64(nil), int64Less, nil, set.WithNumericCoercion(), set.WithBounds(0, 10))
+	if s.Insert(1, int64(2), 11, 3.5, -1) != 2 || !reflect.DeepEqual(s.Slice(), []int64{1, 2}) {
+		t.Fatal(s.Slice())
+	}
+	s = set.NewWithOptions([]int64{5}, int64Less, nil, set.WithNumericCoercion(), set.WithBounds(0, 10))
+	if _, err := s.InsertE(20); err != set.ErrOutOfBounds || s.Insert(7) != 1 || !reflect.DeepEqual(s.Slice(), []int64{5, 7}) {
+		t.Fatal(err, s.Slice())
+	}
+	// built from a nil slice, the set takes the type of the first insert
+	anyLess := func(s1, s2 interface{}) bool { return reflect.ValueOf(s1).Int() < reflect.ValueOf(s2).Int() }
+	s = set.NewWithOptions(nil, anyLess, nil, set.WithNumericCoercion())
+	s.Insert(int8(2), 1)
+	if !reflect.DeepEqual(s.Slice(), []int8{1, 2}) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestImmutabilityCheck(t *testing.T) {
+	s := set.NewWithOptions([]testStruct{{1, 1}, {3, 3}, {2, 2}},
+		func(s1, s2 interface{}) bool { return s1.(testStruct).ID < s2.(testStruct).ID },
+		func(s1, s2 interface{}) bool { return s1.(testStruct).ID == s2.(testStruct).ID },
+		set.WithImmutabilityCheck(),
+	)
+	s.Insert(testStruct{0, 0})
+	s.Erase(testStruct{2, 0})
+	if err := s.Validate(); err != nil || !s.IsSorted() || !s.Has(testStruct{3, 0}, 0) {
+		t.Fatal(err, s.Slice())
+	}
+	arr := s.Slice().([]testStruct)
+	arr[1].Value = 100 // not part of the key
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	arr[1].ID = 2 // still in order
+	err := s.Validate()
+	if e, ok := err.(*set.MutationError); !ok || e.Index != 1 || e.Was.(testStruct).ID != 1 || s.IsSorted() {
+		t.Fatal(err)
+	}
+	s.ReSort()
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	plain := set.Ints([]int{1, 2})
+	plain.Slice().([]int)[0] = 3
+	if plain.Validate() != set.ErrUnsorted || plain.IsSorted() {
+		t.Fatal(plain.Slice())
+	}
+}
+
+func TestOnConflict(t *testing.T) {
+	less := func(s1, s2 interface{}) bool { return s1.(testStruct).ID < s2.(testStruct).ID }
+	equal := func(s1, s2 interface{}) bool { return s1.(testStruct).ID == s2.(testStruct).ID }
+	build := func(opts ...set.Option) set.Set {
+		return set.NewWithOptions([]testStruct{{1, 1}, {2, 2}}, less, equal, opts...)
+	}
+	incoming := []testStruct{{2, 20}, {3, 30}}
+
+	s := build(set.WithOnConflict(set.OnConflictSkip))
+	if s.Insert(incoming) != 1 || !reflect.DeepEqual(s.Slice(), []testStruct{{1, 1}, {2, 2}, {3, 30}}) {
+		t.Fatal(s.Slice())
+	}
+	s = build(set.WithOnConflict(set.OnConflictOverwrite))
+	if s.Insert(incoming) != 1 || s.Insert(testStruct{1, 10}) != 0 ||
+		!reflect.DeepEqual(s.Slice(), []testStruct{{1, 10}, {2, 20}, {3, 30}}) {
+		t.Fatal(s.Slice())
+	}
+	s = build(set.WithMerge(func(existing, incoming interface{}) interface{} {
+		e, i := existing.(testStruct), incoming.(testStruct)
+		return testStruct{e.ID, e.Value + i.Value}
+	}))
+	if s.Insert(incoming) != 1 || !reflect.DeepEqual(s.Slice(), []testStruct{{1, 1}, {2, 22}, {3, 30}}) {
+		t.Fatal(s.Slice())
+	}
+	// past the insertion sort threshold, equal elements keep batch order
+	batch := make([]testStruct, 0, 40)
+	for i := 0; i < 20; i++ {
+		batch = append(batch, testStruct{5, i}, testStruct{4, i})
+	}
+	s = build(set.WithOnConflict(set.OnConflictOverwrite))
+	if s.Insert(batch) != 2 || !reflect.DeepEqual(s.Slice(), []testStruct{{1, 1}, {2, 2}, {4, 19}, {5, 19}}) {
+		t.Fatal(s.Slice())
+	}
+	var folded []int
+	s = build(set.WithMerge(func(existing, incoming interface{}) interface{} {
+		if existing.(testStruct).ID == 5 {
+			folded = append(folded, incoming.(testStruct).Value)
+		}
+		return incoming
+	}))
+	s.Insert(batch)
+	if len(folded) != 19 || !sort.IntsAreSorted(folded) || folded[0] != 1 {
+		t.Fatal(folded)
+	}
+	s = build(set.WithOnConflict(set.OnConflictError))
+	if added, err := s.InsertE(testStruct{0, 0}, incoming); added != 1 || err != set.ErrConflict {
+		t.Fatal(added, err)
+	}
+	if !reflect.DeepEqual(s.Slice(), []testStruct{{0, 0}, {1, 1}, {2, 2}}) {
+		t.Fatal(s.Slice())
+	}
+	if s.Insert(incoming) != 1 || s.Len() != 4 {
+		t.Fatal(s.Slice())
+	}
+	if added, err := set.NewSafe(s).InsertE(testStruct{4, 4}); added != 1 || err != nil {
+		t.Fatal(added, err)
+	}
+	b := set.NewBounded(2, set.EvictMin, nil, intLess, func(s1, s2 interface{}) bool { return s1 == s2 })
+	if added, err := b.InsertE(1, []int{2, 3}); added != 3 || err != nil || !b.Equal([]int{2, 3}) {
+		t.Fatal(added, err, b.Slice())
+	}
+}
+
+func insertionSort(slice interface{}, less func(i, j int) bool) {
+	swap := reflect.Swapper(slice)
+	for i := 1; i < reflect.ValueOf(slice).Len(); i++ {
+		for j := i; j > 0 && less(j, j-1); j-- {
+			swap(j, j-1)
+		}
+	}
+}
+
+func TestSortFunc(t *testing.T) {
+	called := 0
+	s := set.NewWithOptions([]int{3, 1, 2}, intLess, nil, set.WithSortFunc(func(slice interface{}, less func(i, j int) bool) {
+		called++
+		insertionSort(slice, less)
+	}))
+	s.Insert([]int{4, 5, 6})
+	s.Erase([]int{6, 1})
+	if called != 2 || !s.Equal([]int{2, 3, 4, 5}) {
+		t.Fatal(called, s.Slice())
+	}
+}
+
+func BenchmarkSortFunc(b *testing.B) {
+	batch := make([]int, 10000)
+	for i := range batch {
+		batch[i] = i
+	}
+	for i := 0; i+1 < len(batch); i += 100 {
+		batch[i], batch[i+1] = batch[i+1], batch[i]
+	}
+	for _, c := range []struct {
+		name string
+		opts []set.Option
+	}{
+		{"default", nil},
+		{"insertion", []set.Option{set.WithSortFunc(insertionSort)}},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				set.NewWithOptions(append([]int(nil), batch...), intLess, nil, c.opts...)
+			}
+		})
+	}
+}
+
+func TestInsertGuard(t *testing.T) {
+	errAdjacent := errors.New("adjacent")
+	s := set.NewWithOptions([]int{}, intLess, nil, set.WithInsertGuard(func(s set.Set, v interface{}) error {
+		if s.Has(v.(int)-1, 0) || s.Has(v.(int)+1, 0) {
+			return errAdjacent
+		}
+		return nil
+	}))
+	if added, err := s.InsertE(1, 5, []int{9, 4, 7}); added != 3 || err != errAdjacent {
+		t.Fatal(added, err)
+	}
+	if !s.Equal([]int{1, 5, 9}) {
+		t.Fatal(s.Slice())
+	}
+	if added := s.Insert([]int{2, 3, 6, 7, 11}); added != 3 || !s.Equal([]int{1, 3, 5, 7, 9, 11}) {
+		t.Fatal(added, s.Slice())
+	}
+	if added, err := set.NewSafe(s).InsertE(13); added != 1 || err != nil {
+		t.Fatal(added, err)
+	}
+	if s.InsertIfAbsent(12) || s.Toggle(14) || s.Replace(8) != 0 || !s.Equal([]int{1, 3, 5, 7, 9, 11, 13}) {
+		t.Fatal(s.Slice())
+	}
+	if !s.InsertIfAbsent(17) || s.Toggle(17) || !s.Toggle(19) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestBounds(t *testing.T) {
+	s := set.NewWithOptions([]int{-5, 0, 5, 10, 15}, intLess, nil, set.WithBounds(0, 10))
+	if !s.Equal([]int{0, 5, 10}) {
+		t.Fatal(s.Slice())
+	}
+	if added := s.Insert([]int{-1, 3, 11, 7}, 12); added != 2 || !s.Equal([]int{0, 3, 5, 7, 10}) {
+		t.Fatal(added, s.Slice())
+	}
+	if added, err := s.InsertE(4, 20, 6); added != 1 || err != set.ErrOutOfBounds {
+		t.Fatal(added, err)
+	}
+	if s.InsertIfAbsent(100) || s.Toggle(200) || s.Replace(300, []int{400, 8}) != 1 || s.WouldAdd(100) {
+		t.Fatal(s.Slice())
+	}
+	if !s.Equal([]int{0, 3, 4, 5, 7, 8, 10}) || !s.InsertIfAbsent(9) || !set.NewSafe(s).Toggle(1) {
+		t.Fatal(s.Slice())
+	}
+	open := set.NewWithOptions([]int{}, intLess, nil, set.WithBounds(nil, 0))
+	if added := open.Insert(-100, 0, 1); added != 2 {
+		t.Fatal(open.Slice())
+	}
+}
@@ -0,0 +1,37 @@
+package set
+
+// SetReader reads a snapshot of a set which is left unchanged by later
+// writes to the set, so that walks of it are repeatable.
+type SetReader interface {
+	Len() int
+	At(i int) interface{}
+	Has(v interface{}) bool
+	ForEach(fn func(i int, v interface{}) bool)
+}
+
+type reader struct {
+	s Set
+}
+
+func (p *reader) Len() int {
+	return p.s.Len()
+}
+
+// At returns the element at index i.
+func (p *reader) At(i int) interface{} {
+	return valueOf(p.s).Index(i).Interface()
+}
+
+func (p *reader) Has(v interface{}) bool {
+	return p.s.Has(v, 0)
+}
+
+// ForEach calls fn with each element in order until fn returns false.
+func (p *reader) ForEach(fn func(i int, v interface{}) bool) {
+	rv := valueOf(p.s)
+	for i := 0; i < lenOf(rv); i++ {
+		if !fn(i, rv.Index(i).Interface()) {
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package set_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestReader(t *testing.T) {
+	s := set.NewSafe(set.Ints([]int{1, 2, 3}))
+	r1 := s.Reader()
+	s.Insert(4)
+	r2 := s.Reader()
+	s.Erase(1, 2)
+	if r1.Len() != 3 || r1.At(0) != 1 || !r1.Has(2) || r1.Has(4) {
+		t.Fatal(r1.Len())
+	}
+	if r2.Len() != 4 || r2.At(3) != 4 || !s.Equal([]int{3, 4}) {
+		t.Fatal(r2.Len(), s.Slice())
+	}
+	plain := set.Ints([]int{1})
+	r := plain.Reader()
+	plain.Insert(0)
+	if r.Len() != 1 || r.At(0) != 1 {
+		t.Fatal(r.Len())
+	}
+}
+
+func TestReaderRace(t *testing.T) {
+	arr := make([]int, 1000)
+	for i := range arr {
+		arr[i] = i * 2
+	}
+	s := set.NewSafe(set.Ints(arr))
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s.Toggle(g + i*8%2000)
+				s.Erase(i % 2000)
+			}
+		}(g)
+	}
+	for n := 0; n < 20; n++ {
+		r := s.Reader()
+		var pass [2][]interface{}
+		for k := range pass {
+			r.ForEach(func(i int, v interface{}) bool {
+				pass[k] = append(pass[k], v)
+				return true
+			})
+		}
+		if !reflect.DeepEqual(pass[0], pass[1]) || len(pass[0]) != r.Len() {
+			t.Fatal(len(pass[0]), len(pass[1]))
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,34 @@
+package set
+
+// OpKind ...
+type OpKind int
+
+// OpKind values
+const (
+	OpInsert OpKind = iota
+	OpErase
+)
+
+// Op is one recorded set operation. Value is an element or a slice of
+// elements, as passed to Insert or Erase.
+type Op struct {
+	Kind  OpKind
+	Value interface{}
+}
+
+// Replay builds a set by applying ops in order.
+func Replay(ops []Op,
+	less func(s1, s2 interface{}) bool,
+	equal ...func(s1, s2 interface{}) bool,
+) Set {
+	s := New(nil, less, equal...)
+	for _, op := range ops {
+		switch op.Kind {
+		case OpInsert:
+			s.Insert(op.Value)
+		case OpErase:
+			s.Erase(op.Value)
+		}
+	}
+	return s
+}
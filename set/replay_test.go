@@ -0,0 +1,24 @@
+package set_test
+
+import (
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestReplay(t *testing.T) {
+	ops := []set.Op{
+		{Kind: set.OpInsert, Value: []int{5, 1, 3}},
+		{Kind: set.OpInsert, Value: 4},
+		{Kind: set.OpErase, Value: 1},
+		{Kind: set.OpInsert, Value: []int{1, 2}},
+		{Kind: set.OpErase, Value: []int{3, 9}},
+	}
+	s := set.Replay(ops, intLess)
+	if expect := set.Ints([]int{1, 2, 4, 5}); !s.Equal(expect.Slice()) {
+		t.Fatal(s.Slice())
+	}
+	if s := set.Replay(nil, intLess); s.Len() != 0 {
+		t.Fatal(s.Slice())
+	}
+}
@@ -0,0 +1,76 @@
+package set
+
+// Scored is an element of a ScoredSet with its score.
+type Scored struct {
+	Value interface{}
+	Score float64
+}
+
+// ScoredSet keeps elements ordered by a mutable score, looked up by identity.
+type ScoredSet struct {
+	set   *set
+	equal func(s1, s2 interface{}) bool
+}
+
+// NewScored returns an empty ScoredSet ordered by less on scores, highest
+// score first for a nil less. equal tells whether two values are the same
+// element. Elements with equal scores keep the order they were scored in.
+func NewScored(less func(a, b float64) bool, equal func(s1, s2 interface{}) bool) *ScoredSet {
+	if less == nil {
+		less = func(a, b float64) bool { return a > b }
+	}
+	return &ScoredSet{
+		set: New([]Scored{},
+			// a new score goes after the existing ones it ties with
+			func(s1, s2 interface{}) bool { return !less(s2.(Scored).Score, s1.(Scored).Score) },
+			func(s1, s2 interface{}) bool { return false },
+		).(*set),
+		equal: equal,
+	}
+}
+
+// Len ...
+func (p *ScoredSet) Len() int {
+	return p.set.Len()
+}
+
+// Slice returns the elements in score order.
+func (p *ScoredSet) Slice() []Scored {
+	return p.set.Slice().([]Scored)
+}
+
+// Rank returns the position of v in score order, or -1 if absent.
+func (p *ScoredSet) Rank(v interface{}) int {
+	for i, e := range p.Slice() {
+		if p.equal(e.Value, v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Score returns the score of v.
+func (p *ScoredSet) Score(v interface{}) (float64, bool) {
+	i := p.Rank(v)
+	if i < 0 {
+		return 0, false
+	}
+	return p.Slice()[i].Score, true
+}
+
+// SetScore inserts v with score, or moves v to the position of its new
+// score.
+func (p *ScoredSet) SetScore(v interface{}, score float64) {
+	p.Remove(v)
+	p.set.InsertOne(Scored{Value: v, Score: score})
+}
+
+// Remove erases v and reports whether it was present.
+func (p *ScoredSet) Remove(v interface{}) bool {
+	i := p.Rank(v)
+	if i < 0 {
+		return false
+	}
+	p.set.eraseAt(i)
+	return true
+}
@@ -0,0 +1,45 @@
+package set_test
+
+import (
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestScored(t *testing.T) {
+	s := set.NewScored(nil, func(s1, s2 interface{}) bool { return s1 == s2 })
+	s.SetScore("a", 10)
+	s.SetScore("b", 30)
+	s.SetScore("c", 20)
+	s.SetScore("d", 20)
+	order := func() (names []string) {
+		for _, e := range s.Slice() {
+			names = append(names, e.Value.(string))
+		}
+		return
+	}
+	if got := order(); len(got) != 4 || got[0] != "b" || got[1] != "c" || got[2] != "d" || got[3] != "a" {
+		t.Fatal(got)
+	}
+	s.SetScore("a", 25)
+	if s.Rank("a") != 1 || s.Len() != 4 {
+		t.Fatal(order())
+	}
+	s.SetScore("b", 0)
+	if s.Rank("b") != 3 {
+		t.Fatal(order())
+	}
+	if score, ok := s.Score("c"); !ok || score != 20 {
+		t.Fatal(score, ok)
+	}
+	if !s.Remove("c") || s.Remove("c") || s.Rank("c") != -1 || s.Len() != 3 {
+		t.Fatal(order())
+	}
+
+	asc := set.NewScored(func(a, b float64) bool { return a < b }, func(s1, s2 interface{}) bool { return s1 == s2 })
+	asc.SetScore(1, 2)
+	asc.SetScore(2, 1)
+	if asc.Rank(2) != 0 {
+		t.Fatal(asc.Slice())
+	}
+}
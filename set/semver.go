@@ -0,0 +1,55 @@
+package set
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SemVers orders version strings like "1.10.2" by their major, minor and
+// patch numbers, a missing number being 0, so that "1.0" and "1.0.0" are
+// equal. A leading "v" is ignored. Malformed versions, including those with
+// a pre-release or build suffix, follow the others in lexical order.
+var SemVers = func(arr []string) Set {
+	return NewCachedKey(arr,
+		func(v interface{}) interface{} { return parseSemVer(v.(string)) },
+		func(a, b interface{}) bool { return a.(semVer).less(b.(semVer)) },
+	)
+}
+
+type semVer struct {
+	n   [3]int
+	bad string
+	ok  bool
+}
+
+func parseSemVer(s string) semVer {
+	parts := strings.Split(strings.TrimPrefix(s, "v"), ".")
+	if len(parts) > 3 {
+		return semVer{bad: s}
+	}
+	var v semVer
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || part[0] == '+' {
+			return semVer{bad: s}
+		}
+		v.n[i] = n
+	}
+	v.ok = true
+	return v
+}
+
+func (v semVer) less(w semVer) bool {
+	if v.ok != w.ok {
+		return v.ok
+	}
+	if !v.ok {
+		return v.bad < w.bad
+	}
+	for i := range v.n {
+		if v.n[i] != w.n[i] {
+			return v.n[i] < w.n[i]
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package set_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestSemVers(t *testing.T) {
+	s := set.SemVers([]string{"1.9", "1.10", "1.0", "1.0.0"})
+	if !reflect.DeepEqual(s.Slice(), []string{"1.0", "1.9", "1.10"}) {
+		t.Fatal(s.Slice())
+	}
+	if !s.Has("1.10.0", 0) || !s.Has("v1.9", 0) || s.Has("1.1", 0) {
+		t.Fatal(s.Slice())
+	}
+	s.Insert("2.0.0-rc1", "1.10.1", "abc", "0.9.9", "1.2.3.4")
+	want := []string{"0.9.9", "1.0", "1.9", "1.10", "1.10.1", "1.2.3.4", "2.0.0-rc1", "abc"}
+	if !reflect.DeepEqual(s.Slice(), want) {
+		t.Fatal(s.Slice())
+	}
+}
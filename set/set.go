@@ -1,9 +1,16 @@
 package set
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"reflect"
 	"sort"
 	"sync"
+	"time"
 )
 
 // Set ...
@@ -19,43 +26,181 @@ type Set interface {
 
 	Equal(slice interface{}) bool
 	Clone() Set
+	CloneWithCap(extra int) Set
 	Zero() Set
 	New(slice interface{}, sorted bool) Set
 	Intersection(s Set) Set
+	IntersectionFunc(pred func(v interface{}) bool) Set
+	FirstSeenOrder() interface{}
+	ReplaceIf(v interface{}, cond func(existing, incoming interface{}) bool) bool
+	ForEachBatch(batchSize int, fn func(batch []interface{}) bool)
+	SearchHint(v interface{}, hint int) (index int, nextHint int)
+	Cap() int
+	ComparatorName() string
+	Quantile(q float64) (interface{}, bool)
+	Chunks(n int) []Set
+	Reserve(n int)
+	ShrinkToFit()
+	ByteSize() int
+	Get(v interface{}) (interface{}, bool)
+	InsertIfAbsent(v interface{}) bool
+	Gaps() []float64
+	Missing(slice interface{}) interface{}
+	MergeJoin(s Set, visit func(left, right interface{}, side int))
+	Snapshot() Set
+	Since(prev Set) (added, removed interface{})
+	Dedup() int
+	DedupReport() interface{}
+	Checksum() string
+	DrainRange(lo, hi interface{}) interface{}
+	EraseExact(v interface{}, exactEqual func(a, b interface{}) bool) bool
+	FlushBelow(v interface{}) interface{}
+	ToSlice() interface{}
+	Rank(v interface{}) int
+	ForEachCtx(ctx context.Context, fn func(i int, v interface{}) bool) error
+	IsSubset(s Set) bool
+	IsProperSubset(s Set) bool
+	RefreshOrder()
+	Validate() error
+	IsSorted() bool
+	Toggle(v interface{}) (nowPresent bool)
+	UnionCount(s Set) int
+	InsertE(v ...interface{}) (int, error)
+	SymmetricDifferenceIter(s Set) func() (v interface{}, fromLeft bool, ok bool)
+	CountPresent(slice interface{}) int
+	Update(fn func(s Set) error) error
+	IsAliased() bool
+	WriteUnion(w io.Writer, s Set, format func(v interface{}) string) error
+	LongestRun(pred func(v interface{}) bool) (start, length int)
+	Reader() SetReader
+	EraseRangeIf(lo, hi interface{}, pred func(v interface{}) bool) int
+	Neighbors(v interface{}) (below, above interface{}, hasBelow, hasAbove bool)
+	SearchGallop(v interface{}, hint int) (index int, nextHint int)
+	SetComparator(less, equal func(s1, s2 interface{}) bool)
+	UnionMerge(s Set, combine func(a, b interface{}) interface{}) Set
+	FilterAll(preds ...func(v interface{}) bool) Set
+	WouldAdd(v interface{}) bool
+	Reset(slice interface{}, less, equal func(s1, s2 interface{}) bool)
+	ForEachPair(fn func(cur, next interface{}) bool)
+	ContainsSet(s Set) bool
+	Quantiles(n int) interface{}
+	BatchInsertRCU(slice interface{}) int
+	Stream() <-chan interface{}
+	OverlapRange(s Set) (lo, hi interface{}, ok bool)
+	CoalesceBy(same func(a, b interface{}) bool, merge func(a, b interface{}) interface{}) int
+	AtMany(indices []int) interface{}
+	CloneAs(less, equal func(s1, s2 interface{}) bool) Set
+	FirstDiff(s Set) (index int, a, b interface{}, equal bool)
+	SplitAt(pivot interface{}) (greaterOrEqual Set)
+	Pairs(fn func(a, b interface{}) bool)
+	ConvertElem(newLess, newEqual func(a, b interface{}) bool, conv func(v interface{}) interface{}) Set
+	Repair() (fixed int, err error)
+	OrderingInfo() (elemKind reflect.Kind, descending bool, comparatorName string)
+	NewSince(version uint64) (elements interface{}, currentVersion uint64)
+	Diff(s Set) (added, removed interface{})
+	ApplyDiff(added, removed interface{}) (net int)
+	Reindex(keyOf func(s Set, v interface{}) float64)
+	UnionReportConflicts(s Set, equal func(a, b interface{}) bool) (result Set, conflicts interface{})
+	WindowAggregate(width interface{}, agg func(window interface{}) interface{}) interface{}
+	WouldChange(slice interface{}) bool
+	IntGaps() [][2]int
+	EqualSetBy(s Set, eq func(a, b interface{}) bool) bool
+	DistanceTo(s Set) int
+	IsContiguousWith(s Set) bool
+	LocatorFor(v interface{}) Locator
 }
 
 // New ...
 func New(slice interface{},
 	less func(s1, s2 interface{}) bool,
 	equal ...func(s1, s2 interface{}) bool,
+) Set {
+	var eq func(s1, s2 interface{}) bool
+	if len(equal) > 0 {
+		eq = equal[0]
+	}
+	return NewWithOptions(slice, less, eq)
+}
+
+// NewWithOptions is like New, with construction options applied before the
+// initial elements are inserted. A nil equal defaults to reflect.DeepEqual.
+func NewWithOptions(slice interface{},
+	less func(s1, s2 interface{}) bool,
+	equal func(s1, s2 interface{}) bool,
+	opts ...Option,
 ) Set {
 	s := &set{
-		less: less,
-		lessFunc: func(s interface{}) func(i, j int) bool {
-			return func(i, j int) bool {
-				rv := reflect.ValueOf(s)
-				return less(rv.Index(i).Interface(), rv.Index(j).Interface())
-			}
-		},
+		opt: &options{shrinkFactor: defaultShrinkFactor},
 	}
-	if len(equal) > 0 {
-		s.equal = equal[0]
-	} else {
-		s.equal = func(s1, s2 interface{}) bool {
-			ok := reflect.DeepEqual(s1, s2)
-			return ok
-		}
+	for _, opt := range opts {
+		opt(s.opt)
 	}
+	s.setComparator(less, equal)
 	if slice == nil {
 		return s
 	}
-	s.swaper = reflect.Swapper(slice)
 	rv := reflect.ValueOf(slice)
+	if rv.Kind() == reflect.Array {
+		// copy arrays into a slice, leaving the caller's array untouched
+		arr := reflect.MakeSlice(reflect.SliceOf(rv.Type().Elem()), rv.Len(), rv.Len())
+		reflect.Copy(arr, rv)
+		rv, slice = arr, arr.Interface()
+	}
+	if s.opt.deref {
+		slice = s.deref(slice)
+		rv = reflect.ValueOf(slice)
+	}
 	if rv.Len() == 0 {
 		s.rv = rv
 	} else {
 		s.rv = reflect.Zero(reflect.TypeOf(slice))
-		s.InsertSlice(slice, false)
+	}
+	if s.opt.coerce {
+		s.opt.min, s.opt.max = s.coerce(s.opt.min), s.coerce(s.opt.max)
+	}
+	if rv.Len() > 0 {
+		s.Insert(slice)
+	}
+	return s
+}
+
+// NewFromSortedNoCopy returns a set backed by slice itself, which must be
+// sorted and free of duplicates. Changes through slice show in the set
+// until it reallocates; see IsAliased.
+func NewFromSortedNoCopy(slice interface{},
+	less func(s1, s2 interface{}) bool,
+	equal ...func(s1, s2 interface{}) bool,
+) Set {
+	return New(nil, less, equal...).(*set).SetSlice(slice)
+}
+
+// NewFromSortedReadOnly is NewFromSortedNoCopy for a slice which must not
+// be written to, such as one in read-only memory. The set copies it on the
+// first change, and changes through slice show in the set until then.
+func NewFromSortedReadOnly(slice interface{},
+	less func(s1, s2 interface{}) bool,
+	equal ...func(s1, s2 interface{}) bool,
+) Set {
+	s := NewFromSortedNoCopy(slice, less, equal...).(*set)
+	s.cow = true
+	return s
+}
+
+// NewGenerated returns a set of the values of gen, inserted in turn until
+// gen reports false, with no value, or until(s) holds, which is also checked
+// before the first call.
+func NewGenerated(gen func() (interface{}, bool),
+	until func(s Set) bool,
+	less func(s1, s2 interface{}) bool,
+	equal ...func(s1, s2 interface{}) bool,
+) Set {
+	s := New(nil, less, equal...)
+	for !until(s) {
+		v, ok := gen()
+		if !ok {
+			break
+		}
+		s.Insert(v)
 	}
 	return s
 }
@@ -71,6 +216,20 @@ func NewSafe(s Set) Set {
 type safeSet struct {
 	set Set
 	sync.RWMutex
+	// shared tells that set is held by a reader, and must be copied before
+	// the next write.
+	shared bool
+	// version counts the writes, for locators to tell when to search again.
+	version uint64
+}
+
+// own copies a shared set before a write, with the write lock held.
+func (p *safeSet) own() {
+	p.version++
+	if p.shared {
+		p.set = p.set.Clone()
+		p.shared = false
+	}
 }
 
 // var _ Set = (*safeSet)(nil)
@@ -83,10 +242,7 @@ func (p *safeSet) Len() int {
 }
 
 func (p *safeSet) Slice() interface{} {
-	p.RLock()
-	s := p.set.Clone()
-	p.RUnlock()
-	return s.Slice()
+	return p.ToSlice()
 }
 
 func (p *safeSet) Search(v interface{}, pos int) int {
@@ -105,6 +261,7 @@ func (p *safeSet) Has(v interface{}, pos int) bool {
 
 func (p *safeSet) Insert(v ...interface{}) int {
 	p.Lock()
+	p.own()
 	n := p.set.Insert(v...)
 	p.Unlock()
 	return n
@@ -112,6 +269,7 @@ func (p *safeSet) Insert(v ...interface{}) int {
 
 func (p *safeSet) Replace(v ...interface{}) int {
 	p.Lock()
+	p.own()
 	n := p.set.Replace(v...)
 	p.Unlock()
 	return n
@@ -119,12 +277,16 @@ func (p *safeSet) Replace(v ...interface{}) int {
 
 func (p *safeSet) Erase(v ...interface{}) int {
 	p.Lock()
+	p.own()
 	n := p.set.Erase(v...)
 	p.Unlock()
 	return n
 }
 
 func (p *safeSet) Equal(slice interface{}) bool {
+	if s, ok := slice.(Set); ok {
+		slice = unlocked(s)
+	}
 	p.RLock()
 	ok := p.set.Equal(slice)
 	p.RUnlock()
@@ -140,6 +302,15 @@ func (p *safeSet) Clone() Set {
 	}
 }
 
+func (p *safeSet) CloneWithCap(extra int) Set {
+	p.RLock()
+	s := p.set.CloneWithCap(extra)
+	p.RUnlock()
+	return &safeSet{
+		set: s,
+	}
+}
+
 func (p *safeSet) Zero() Set {
 	return &safeSet{
 		set: p.set.Zero(),
@@ -152,394 +323,2951 @@ func (p *safeSet) New(slice interface{}, sorted bool) Set {
 	}
 }
 
+// Intersection unlocks if the comparator check panics.
 func (p *safeSet) Intersection(s Set) Set {
-	p.Lock()
-	p.set.Intersection(s)
-	p.Unlock()
-	return p
+	s = unlocked(s)
+	p.RLock()
+	defer p.RUnlock()
+	return &safeSet{
+		set: p.set.Intersection(s),
+	}
+}
+
+func (p *safeSet) IntersectionFunc(pred func(v interface{}) bool) Set {
+	p.RLock()
+	s := p.set.IntersectionFunc(pred)
+	p.RUnlock()
+	return &safeSet{
+		set: s,
+	}
+}
+
+func (p *safeSet) FirstSeenOrder() interface{} {
+	p.RLock()
+	order := p.set.FirstSeenOrder()
+	p.RUnlock()
+	return order
 }
 
 func (p *safeSet) ReSort() {
 	p.Lock()
+	p.own()
 	p.set.ReSort()
 	p.Unlock()
 }
 
-// ReflectMove ...
-func ReflectMove(rv reflect.Value, dstPos, srcPos, n int) {
-	reflect.Copy(rv.Slice(dstPos, dstPos+n), rv.Slice(srcPos, srcPos+n))
+func (p *safeSet) ReplaceIf(v interface{}, cond func(existing, incoming interface{}) bool) bool {
+	p.Lock()
+	p.own()
+	ok := p.set.ReplaceIf(v, cond)
+	p.Unlock()
+	return ok
 }
 
-// ReflectInsertAt ...
-func ReflectInsertAt(slice reflect.Value, v reflect.Value, pos int) (newSlice reflect.Value) {
-	newSlice = reflect.Append(slice, v)
-	ReflectMove(newSlice, pos+1, pos, newSlice.Len()-(pos+1))
-	newSlice.Index(pos).Set(v)
-	return
+// ForEachBatch copies up to batchSize elements under a brief read lock and
+// calls fn on the copy with the lock released. Writers may run between
+// batches, so the walk is not a consistent snapshot: elements inserted or
+// erased meanwhile may be skipped or seen twice.
+func (p *safeSet) ForEachBatch(batchSize int, fn func(batch []interface{}) bool) {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	for offset := 0; ; offset += batchSize {
+		p.RLock()
+		batch := sliceBatch(reflect.ValueOf(p.set.Slice()), offset, batchSize)
+		p.RUnlock()
+		if len(batch) == 0 || !fn(batch) {
+			return
+		}
+	}
 }
 
-// ReflectErase ...
-func ReflectErase(slice reflect.Value, pos int) reflect.Value {
-	if pos >= slice.Len() {
-		return slice
+func (p *safeSet) SearchHint(v interface{}, hint int) (int, int) {
+	p.RLock()
+	index, nextHint := p.set.SearchHint(v, hint)
+	p.RUnlock()
+	return index, nextHint
+}
+
+func (p *safeSet) Cap() int {
+	p.RLock()
+	n := p.set.Cap()
+	p.RUnlock()
+	return n
+}
+
+func (p *safeSet) ComparatorName() string {
+	p.RLock()
+	name := p.set.ComparatorName()
+	p.RUnlock()
+	return name
+}
+
+func (p *safeSet) Quantile(q float64) (interface{}, bool) {
+	p.RLock()
+	v, ok := p.set.Quantile(q)
+	p.RUnlock()
+	return v, ok
+}
+
+func (p *safeSet) Chunks(n int) []Set {
+	p.RLock()
+	chunks := p.set.Chunks(n)
+	p.RUnlock()
+	for i, s := range chunks {
+		chunks[i] = &safeSet{
+			set: s,
+		}
 	}
-	if pos < slice.Len()-1 {
-		ReflectMove(slice, pos, pos+1, slice.Len()-(pos+1))
+	return chunks
+}
+
+func (p *safeSet) Reserve(n int) {
+	p.Lock()
+	p.own()
+	p.set.Reserve(n)
+	p.Unlock()
+}
+
+func (p *safeSet) ShrinkToFit() {
+	p.Lock()
+	p.own()
+	p.set.ShrinkToFit()
+	p.Unlock()
+}
+
+func (p *safeSet) ByteSize() int {
+	p.RLock()
+	n := p.set.ByteSize()
+	p.RUnlock()
+	return n
+}
+
+func (p *safeSet) Get(v interface{}) (interface{}, bool) {
+	p.RLock()
+	e, ok := p.set.Get(v)
+	p.RUnlock()
+	return e, ok
+}
+
+func (p *safeSet) InsertIfAbsent(v interface{}) bool {
+	p.Lock()
+	p.own()
+	ok := p.set.InsertIfAbsent(v)
+	p.Unlock()
+	return ok
+}
+
+func (p *safeSet) Gaps() []float64 {
+	p.RLock()
+	gaps := p.set.Gaps()
+	p.RUnlock()
+	return gaps
+}
+
+func (p *safeSet) Missing(slice interface{}) interface{} {
+	p.RLock()
+	missing := p.set.Missing(slice)
+	p.RUnlock()
+	return missing
+}
+
+func (p *safeSet) MergeJoin(s Set, visit func(left, right interface{}, side int)) {
+	other := s.Clone()
+	p.RLock()
+	p.set.MergeJoin(other, visit)
+	p.RUnlock()
+}
+
+// Snapshot returns a plain copy taken under the read lock.
+func (p *safeSet) Snapshot() Set {
+	p.RLock()
+	s := p.set.Clone()
+	p.RUnlock()
+	return s
+}
+
+// unlocked returns a snapshot of a set argument which may hold a lock, such
+// as the receiver itself, so that it is not locked while the receiver is.
+// Plain sets, which hold none, are returned as is.
+func unlocked(s Set) Set {
+	if _, ok := s.(interface{ plain() *set }); ok {
+		return s
 	}
-	return slice.Slice(0, slice.Len()-1)
+	return s.Snapshot()
 }
 
-type set struct {
-	rv       reflect.Value
-	less     func(s1, s2 interface{}) bool
-	equal    func(s1, s2 interface{}) bool
-	swaper   func(i, j int)
-	lessFunc func(slice interface{}) func(i, j int) bool
+func (p *safeSet) Since(prev Set) (added, removed interface{}) {
+	prev = unlocked(prev)
+	p.RLock()
+	added, removed = p.set.Since(prev)
+	p.RUnlock()
+	return
+}
+
+func (p *safeSet) Dedup() int {
+	p.Lock()
+	p.own()
+	n := p.set.Dedup()
+	p.Unlock()
+	return n
+}
+
+func (p *safeSet) DedupReport() interface{} {
+	p.Lock()
+	p.own()
+	removed := p.set.DedupReport()
+	p.Unlock()
+	return removed
+}
+
+func (p *safeSet) Checksum() string {
+	p.RLock()
+	sum := p.set.Checksum()
+	p.RUnlock()
+	return sum
+}
+
+func (p *safeSet) DrainRange(lo, hi interface{}) interface{} {
+	p.Lock()
+	p.own()
+	drained := p.set.DrainRange(lo, hi)
+	p.Unlock()
+	return drained
+}
+
+func (p *safeSet) EraseExact(v interface{}, exactEqual func(a, b interface{}) bool) bool {
+	p.Lock()
+	p.own()
+	ok := p.set.EraseExact(v, exactEqual)
+	p.Unlock()
+	return ok
+}
+
+func (p *safeSet) FlushBelow(v interface{}) interface{} {
+	p.Lock()
+	p.own()
+	flushed := p.set.FlushBelow(v)
+	p.Unlock()
+	return flushed
+}
+
+func (p *safeSet) ToSlice() interface{} {
+	p.RLock()
+	slice := p.set.ToSlice()
+	p.RUnlock()
+	return slice
+}
+
+func (p *safeSet) Rank(v interface{}) int {
+	p.RLock()
+	rank := p.set.Rank(v)
+	p.RUnlock()
+	return rank
+}
+
+// ForEachCtx holds the read lock for the walk, which ends soon after ctx is
+// done.
+func (p *safeSet) ForEachCtx(ctx context.Context, fn func(i int, v interface{}) bool) error {
+	p.RLock()
+	err := p.set.ForEachCtx(ctx, fn)
+	p.RUnlock()
+	return err
+}
+
+func (p *safeSet) IsSubset(s Set) bool {
+	s = unlocked(s)
+	p.RLock()
+	ok := p.set.IsSubset(s)
+	p.RUnlock()
+	return ok
+}
+
+func (p *safeSet) IsProperSubset(s Set) bool {
+	s = unlocked(s)
+	p.RLock()
+	ok := p.set.IsProperSubset(s)
+	p.RUnlock()
+	return ok
+}
+
+func (p *safeSet) RefreshOrder() {
+	p.Lock()
+	p.own()
+	p.set.RefreshOrder()
+	p.Unlock()
+}
+
+func (p *safeSet) Validate() error {
+	p.RLock()
+	err := p.set.Validate()
+	p.RUnlock()
+	return err
+}
+
+func (p *safeSet) IsSorted() bool {
+	p.RLock()
+	ok := p.set.IsSorted()
+	p.RUnlock()
+	return ok
+}
+
+func (p *safeSet) Toggle(v interface{}) bool {
+	p.Lock()
+	p.own()
+	present := p.set.Toggle(v)
+	p.Unlock()
+	return present
+}
+
+func (p *safeSet) UnionCount(s Set) int {
+	s = unlocked(s)
+	p.RLock()
+	n := p.set.UnionCount(s)
+	p.RUnlock()
+	return n
+}
+
+func (p *safeSet) InsertE(v ...interface{}) (int, error) {
+	p.Lock()
+	p.own()
+	added, err := p.set.InsertE(v...)
+	p.Unlock()
+	return added, err
+}
+
+// SymmetricDifferenceIter walks a snapshot taken under the read lock.
+func (p *safeSet) SymmetricDifferenceIter(s Set) func() (v interface{}, fromLeft bool, ok bool) {
+	return p.Snapshot().SymmetricDifferenceIter(s)
+}
+
+func (p *safeSet) CountPresent(slice interface{}) int {
+	p.RLock()
+	n := p.set.CountPresent(slice)
+	p.RUnlock()
+	return n
+}
+
+// Update runs fn under a single write lock, so that fn may read and change
+// the set with no other writer in between.
+func (p *safeSet) Update(fn func(s Set) error) error {
+	p.Lock()
+	defer p.Unlock()
+	s := p.set.Clone()
+	if err := fn(s); err != nil {
+		return err
+	}
+	p.set = s
+	p.shared = false
+	p.version++
+	return nil
+}
+
+func (p *safeSet) IsAliased() bool {
+	p.RLock()
+	ok := p.set.IsAliased()
+	p.RUnlock()
+	return ok
+}
+
+func (p *safeSet) WriteUnion(w io.Writer, s Set, format func(v interface{}) string) error {
+	s = unlocked(s)
+	p.RLock()
+	err := p.set.WriteUnion(w, s, format)
+	p.RUnlock()
+	return err
+}
+
+func (p *safeSet) LongestRun(pred func(v interface{}) bool) (start, length int) {
+	p.RLock()
+	start, length = p.set.LongestRun(pred)
+	p.RUnlock()
+	return
+}
+
+// Reader shares the current elements with the returned reader, and the next
+// write copies them first, so a reader costs one copy when writes follow.
+func (p *safeSet) Reader() SetReader {
+	p.Lock()
+	p.shared = true
+	r := &reader{s: p.set}
+	p.Unlock()
+	return r
+}
+
+func (p *safeSet) EraseRangeIf(lo, hi interface{}, pred func(v interface{}) bool) int {
+	p.Lock()
+	p.own()
+	n := p.set.EraseRangeIf(lo, hi, pred)
+	p.Unlock()
+	return n
+}
+
+func (p *safeSet) Neighbors(v interface{}) (below, above interface{}, hasBelow, hasAbove bool) {
+	p.RLock()
+	below, above, hasBelow, hasAbove = p.set.Neighbors(v)
+	p.RUnlock()
+	return
+}
+
+func (p *safeSet) SearchGallop(v interface{}, hint int) (int, int) {
+	p.RLock()
+	index, next := p.set.SearchGallop(v, hint)
+	p.RUnlock()
+	return index, next
+}
+
+// SetComparator re-sorts under the write lock, so readers see the set either
+// before or after the change.
+func (p *safeSet) SetComparator(less, equal func(s1, s2 interface{}) bool) {
+	p.Lock()
+	p.own()
+	p.set.SetComparator(less, equal)
+	p.Unlock()
+}
+
+func (p *safeSet) UnionMerge(s Set, combine func(a, b interface{}) interface{}) Set {
+	s = unlocked(s)
+	p.RLock()
+	defer p.RUnlock()
+	return &safeSet{set: p.set.UnionMerge(s, combine)}
+}
+
+func (p *safeSet) FilterAll(preds ...func(v interface{}) bool) Set {
+	p.RLock()
+	s := p.set.FilterAll(preds...)
+	p.RUnlock()
+	return &safeSet{
+		set: s,
+	}
+}
+
+func (p *safeSet) WouldAdd(v interface{}) bool {
+	p.RLock()
+	ok := p.set.WouldAdd(v)
+	p.RUnlock()
+	return ok
+}
+
+// Reset holds the write lock throughout, so readers never see the old
+// elements with the new comparators.
+func (p *safeSet) Reset(slice interface{}, less, equal func(s1, s2 interface{}) bool) {
+	p.Lock()
+	p.own()
+	p.set.Reset(slice, less, equal)
+	p.Unlock()
+}
+
+func (p *safeSet) ForEachPair(fn func(cur, next interface{}) bool) {
+	p.RLock()
+	p.set.ForEachPair(fn)
+	p.RUnlock()
+}
+
+func (p *safeSet) ContainsSet(s Set) bool {
+	s = unlocked(s)
+	p.RLock()
+	ok := p.set.ContainsSet(s)
+	p.RUnlock()
+	return ok
+}
+
+func (p *safeSet) Quantiles(n int) interface{} {
+	p.RLock()
+	bounds := p.set.Quantiles(n)
+	p.RUnlock()
+	return bounds
+}
+
+// BatchInsertRCU inserts slice into a copy of the set with no lock held,
+// then swaps the copy in under a brief write lock, so readers are blocked
+// only by the swap, seeing the old elements until then. Writes made during
+// the insert are lost, so the caller must serialize its writers around it.
+func (p *safeSet) BatchInsertRCU(slice interface{}) int {
+	p.RLock()
+	s := p.set.Clone()
+	p.RUnlock()
+	added := s.Insert(slice)
+	p.Lock()
+	p.set = s
+	p.shared = false
+	p.version++
+	p.Unlock()
+	return added
+}
+
+func (p *safeSet) Stream() <-chan interface{} {
+	p.RLock()
+	ch := p.set.Stream()
+	p.RUnlock()
+	return ch
+}
+
+func (p *safeSet) OverlapRange(s Set) (lo, hi interface{}, ok bool) {
+	s = unlocked(s)
+	p.RLock()
+	lo, hi, ok = p.set.OverlapRange(s)
+	p.RUnlock()
+	return
+}
+
+func (p *safeSet) CoalesceBy(same func(a, b interface{}) bool, merge func(a, b interface{}) interface{}) int {
+	p.Lock()
+	p.own()
+	n := p.set.CoalesceBy(same, merge)
+	p.Unlock()
+	return n
+}
+
+func (p *safeSet) AtMany(indices []int) interface{} {
+	p.RLock()
+	elems := p.set.AtMany(indices)
+	p.RUnlock()
+	return elems
+}
+
+func (p *safeSet) CloneAs(less, equal func(s1, s2 interface{}) bool) Set {
+	p.RLock()
+	s := p.set.CloneAs(less, equal)
+	p.RUnlock()
+	return &safeSet{
+		set: s,
+	}
+}
+
+func (p *safeSet) FirstDiff(s Set) (index int, a, b interface{}, equal bool) {
+	s = unlocked(s)
+	p.RLock()
+	index, a, b, equal = p.set.FirstDiff(s)
+	p.RUnlock()
+	return
+}
+
+func (p *safeSet) SplitAt(pivot interface{}) (greaterOrEqual Set) {
+	p.Lock()
+	p.own()
+	s := p.set.SplitAt(pivot)
+	p.Unlock()
+	return &safeSet{
+		set: s,
+	}
+}
+
+func (p *safeSet) Pairs(fn func(a, b interface{}) bool) {
+	p.RLock()
+	p.set.Pairs(fn)
+	p.RUnlock()
+}
+
+func (p *safeSet) ConvertElem(newLess, newEqual func(a, b interface{}) bool, conv func(v interface{}) interface{}) Set {
+	p.RLock()
+	s := p.set.ConvertElem(newLess, newEqual, conv)
+	p.RUnlock()
+	return &safeSet{
+		set: s,
+	}
+}
+
+func (p *safeSet) Repair() (fixed int, err error) {
+	p.Lock()
+	p.own()
+	fixed, err = p.set.Repair()
+	p.Unlock()
+	return
+}
+
+func (p *safeSet) OrderingInfo() (elemKind reflect.Kind, descending bool, comparatorName string) {
+	p.RLock()
+	elemKind, descending, comparatorName = p.set.OrderingInfo()
+	p.RUnlock()
+	return
+}
+
+func (p *safeSet) NewSince(version uint64) (elements interface{}, currentVersion uint64) {
+	p.RLock()
+	elements, currentVersion = p.set.NewSince(version)
+	p.RUnlock()
+	return
+}
+
+func (p *safeSet) Diff(s Set) (added, removed interface{}) {
+	s = unlocked(s)
+	p.RLock()
+	added, removed = p.set.Diff(s)
+	p.RUnlock()
+	return
+}
+
+func (p *safeSet) ApplyDiff(added, removed interface{}) (net int) {
+	p.Lock()
+	p.own()
+	net = p.set.ApplyDiff(added, removed)
+	p.Unlock()
+	return
+}
+
+// Reindex passes keyOf the inner set, as the lock is held.
+func (p *safeSet) Reindex(keyOf func(s Set, v interface{}) float64) {
+	p.Lock()
+	p.own()
+	p.set.Reindex(keyOf)
+	p.Unlock()
+}
+
+func (p *safeSet) UnionReportConflicts(s Set, equal func(a, b interface{}) bool) (result Set, conflicts interface{}) {
+	s = unlocked(s)
+	p.RLock()
+	defer p.RUnlock()
+	result, conflicts = p.set.UnionReportConflicts(s, equal)
+	return &safeSet{set: result}, conflicts
+}
+
+func (p *safeSet) WindowAggregate(width interface{}, agg func(window interface{}) interface{}) interface{} {
+	p.RLock()
+	aggs := p.set.WindowAggregate(width, agg)
+	p.RUnlock()
+	return aggs
+}
+
+func (p *safeSet) WouldChange(slice interface{}) bool {
+	p.RLock()
+	ok := p.set.WouldChange(slice)
+	p.RUnlock()
+	return ok
+}
+
+func (p *safeSet) IntGaps() [][2]int {
+	p.RLock()
+	gaps := p.set.IntGaps()
+	p.RUnlock()
+	return gaps
+}
+
+func (p *safeSet) EqualSetBy(s Set, eq func(a, b interface{}) bool) bool {
+	s = unlocked(s)
+	p.RLock()
+	ok := p.set.EqualSetBy(s, eq)
+	p.RUnlock()
+	return ok
+}
+
+func (p *safeSet) DistanceTo(s Set) int {
+	s = unlocked(s)
+	p.RLock()
+	n := p.set.DistanceTo(s)
+	p.RUnlock()
+	return n
+}
+
+func (p *safeSet) IsContiguousWith(s Set) bool {
+	s = unlocked(s)
+	p.RLock()
+	ok := p.set.IsContiguousWith(s)
+	p.RUnlock()
+	return ok
+}
+
+func (p *safeSet) LocatorFor(v interface{}) Locator {
+	return &safeLocator{s: p, v: v}
+}
+
+// ReflectMove ...
+func ReflectMove(rv reflect.Value, dstPos, srcPos, n int) {
+	reflect.Copy(rv.Slice(dstPos, dstPos+n), rv.Slice(srcPos, srcPos+n))
+}
+
+// ReflectInsertAt ...
+func ReflectInsertAt(slice reflect.Value, v reflect.Value, pos int) (newSlice reflect.Value) {
+	newSlice = reflect.Append(slice, v)
+	ReflectMove(newSlice, pos+1, pos, newSlice.Len()-(pos+1))
+	newSlice.Index(pos).Set(v)
+	return
+}
+
+// ReflectErase ...
+func ReflectErase(slice reflect.Value, pos int) reflect.Value {
+	if pos >= slice.Len() {
+		return slice
+	}
+	if pos < slice.Len()-1 {
+		ReflectMove(slice, pos, pos+1, slice.Len()-(pos+1))
+	}
+	return slice.Slice(0, slice.Len()-1)
+}
+
+type set struct {
+	rv       reflect.Value
+	less     func(s1, s2 interface{}) bool
+	equal    func(s1, s2 interface{}) bool
+	swaper   func(i, j int)
+	lessFunc func(slice interface{}) func(i, j int) bool
+	opt      *options
+	// seq is the next insertion sequence number, seqs holds the
+	// sequence number of each element when tracking insertion order.
+	seq  uint64
+	seqs []uint64
+	// keys caches the key of each element when built by NewCachedKey, or a
+	// copy of each element when built WithImmutabilityCheck.
+	keys []interface{}
+	// aliased is the backing array of a caller's slice adopted without
+	// copying.
+	aliased uintptr
+	// cow tells that the backing slice is a read-only one of the caller's,
+	// to copy before the first write.
+	cow bool
+	// reserved is the capacity asked for by Reserve or CloneWithCap, below
+	// which erasing does not shrink the backing slice.
+	reserved int
+}
+
+var _ Set = (*set)(nil)
+
+func (p set) Len() int {
+	if !p.rv.IsValid() {
+		return 0
+	}
+	return p.rv.Len()
+}
+
+func (p set) Slice() interface{} {
+	if !p.rv.IsValid() {
+		return nil
+	}
+	return p.rv.Interface()
+}
+
+func (p set) Search(v interface{}, pos int) int {
+	v = p.normalize(v)
+	if p.opt.keyLess != nil && !isInexact(v) {
+		key := p.opt.keyOf(v)
+		return sort.Search(p.Len()-pos, func(i int) bool {
+			return !p.opt.keyLess(p.keys[pos+i], key)
+		})
+	}
+	return sort.Search(p.Len()-pos, func(i int) bool {
+		return !p.less(p.rv.Index(pos+i).Interface(), v)
+	})
+}
+
+func (p set) hasOne(v interface{}, pos int) bool {
+	_, found := p.find(v, pos)
+	return found
+}
+
+// find returns the index of the first element from pos not less than v,
+// and whether it equals v. A set built by NewCachedKey compares the cached
+// key of the element rather than calling equal, which would compute it.
+func (p set) find(v interface{}, pos int) (i int, found bool) {
+	v = p.normalize(v)
+	if p.opt.keyEqual && p.opt.keyLess != nil && !isInexact(v) {
+		key := p.opt.keyOf(v)
+		i = pos + sort.Search(p.Len()-pos, func(i int) bool {
+			return !p.opt.keyLess(p.keys[pos+i], key)
+		})
+		return i, i < p.Len() && !p.opt.keyLess(key, p.keys[i])
+	}
+	i = pos + p.Search(v, pos)
+	return i, i < p.Len() && p.equal(p.rv.Index(i).Interface(), v)
+}
+
+func (p set) hasSlice(slice interface{}, pos int) bool {
+	p.sort(slice)
+	rv := reflect.ValueOf(slice)
+	if rv.Len() > p.rv.Len() {
+		return false
+	}
+	if p.rv.Len() == 0 {
+		return true
+	}
+
+	for i := 0; i < rv.Len() && pos < p.rv.Len(); i++ {
+		var found bool
+		if pos, found = p.find(rv.Index(i).Interface(), pos); !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (p set) Has(v interface{}, pos int) bool {
+	if !p.rv.IsValid() {
+		return false
+	}
+	n := p.normalize(v)
+	if reflect.TypeOf(n) == p.rv.Type() {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.Len() != reflect.ValueOf(n).Len() {
+			// normalize dropped an inexact or nil element, which is absent
+			return false
+		}
+		return p.hasSlice(n, pos)
+	}
+	return p.hasOne(n, pos)
+}
+
+// Insert adds the elements, which may be slices of them, and returns the
+// number added. Nil elements, which InsertE rejects, are skipped.
+func (p *set) Insert(v ...interface{}) (added int) {
+	if p.guarded() {
+		// guarded elements are checked one at a time
+		p.each(v, func(e interface{}) error {
+			if !isNil(e) && p.admit(e) == nil {
+				added += p.InsertOne(e)
+			}
+			return nil
+		})
+		return
+	}
+	for _, arg := range v {
+		arg = p.normalize(arg)
+		if isNil(arg) {
+			continue
+		}
+		rv := reflect.ValueOf(arg)
+		if rv.Type().Kind() == reflect.Slice {
+			added += p.InsertSlice(dropNil(rv).Interface(), false)
+			continue
+		}
+		added += p.InsertOne(arg)
+	}
+	return
+}
+
+// Replace is Insert storing the elements in place of those equal to them.
+func (p *set) Replace(v ...interface{}) (replaced int) {
+	if p.guarded() {
+		p.each(v, func(e interface{}) error {
+			if !isNil(e) && p.admit(e) == nil {
+				replaced += p.ReplaceOne(e)
+			}
+			return nil
+		})
+		return
+	}
+	for _, arg := range v {
+		arg = p.normalize(arg)
+		if isNil(arg) {
+			continue
+		}
+		rv := reflect.ValueOf(arg)
+		if rv.Type().Kind() == reflect.Slice {
+			replaced += p.ReplaceSlice(dropNil(rv).Interface(), false)
+			continue
+		}
+		replaced += p.ReplaceOne(arg)
+	}
+	return
+}
+
+func (p *set) Erase(v ...interface{}) (added int) {
+	if !p.rv.IsValid() {
+		return
+	}
+	for _, arg := range v {
+		arg = p.normalize(arg)
+		rv := reflect.ValueOf(arg)
+		if rv.Type() == p.rv.Type() {
+			added += p.EraseSlice(arg, false)
+			continue
+		}
+		added += p.EraseOne(arg)
+	}
+	return
+}
+
+func (p set) sort(slice interface{}) {
+	lf := p.lessFunc(slice)
+	if sort.SliceIsSorted(slice, lf) {
+		return
+	}
+	if p.opt.sortFunc != nil {
+		p.opt.sortFunc(slice, lf)
+		return
+	}
+	if p.opt.conflict == OnConflictOverwrite || p.opt.conflict == OnConflictMerge {
+		// keep equal elements in batch order, so that the last one wins
+		sort.SliceStable(slice, lf)
+		return
+	}
+	sort.Slice(slice, lf)
+}
+
+func (p *set) InsertSlice(slice interface{}, sorted bool) (added int) {
+	if (p.opt.trackOrder || p.opt.keyLess != nil) && !sorted {
+		// insert in the caller's order so that sequence numbers follow it,
+		// and search by the cached keys rather than sorting with less
+		rv := reflect.ValueOf(slice)
+		for i := 0; i < rv.Len(); i++ {
+			added += p.InsertOne(rv.Index(i).Interface())
+		}
+		return
+	}
+	p.init(reflect.TypeOf(slice))
+	if !sorted {
+		p.sort(slice)
+	}
+	if p.rv.Len() == 0 && sorted {
+		p.adopt(reflect.ValueOf(slice))
+		added = p.rv.Len()
+		return
+	}
+	rv := reflect.ValueOf(slice)
+	pos := 0
+	for i := 0; i < rv.Len(); i++ {
+		if p.rv.Len() == 0 {
+			p.insertAt(rv.Index(i), 0)
+			added++
+			continue
+		}
+		ri := rv.Index(i)
+		v := ri.Interface()
+		pos += p.Search(v, pos)
+		n := pos
+		if pos < p.rv.Len() {
+			e := p.rv.Index(pos).Interface()
+			if p.equal(e, v) {
+				// has v
+				p.mergeAt(pos, v)
+				continue
+			} else if p.less(e, v) {
+				// less than v, insert after e
+				n++
+			}
+		} else {
+			pos--
+		}
+		added++
+		p.insertAt(ri, n)
+		if pos > 0 {
+			pos--
+		}
+	}
+	return
+}
+
+func (p *set) InsertOne(v interface{}) (added int) {
+	if isInexact(v) {
+		return
+	}
+	p.init(reflect.SliceOf(reflect.TypeOf(v)))
+	if p.rv.Len() == 0 {
+		p.insertAt(reflect.ValueOf(v), 0)
+		added++
+		return
+	}
+	pos, found := p.find(v, 0)
+	if found {
+		// has v
+		p.mergeAt(pos, v)
+		return
+	}
+
+	p.insertAt(reflect.ValueOf(v), pos)
+	added++
+	return
+}
+
+func (p *set) ReplaceSlice(slice interface{}, sorted bool) (replaced int) {
+	if (p.opt.trackOrder || p.opt.keyLess != nil) && !sorted {
+		// as in InsertSlice, replace in the caller's order
+		rv := reflect.ValueOf(slice)
+		for i := 0; i < rv.Len(); i++ {
+			replaced += p.ReplaceOne(rv.Index(i).Interface())
+		}
+		return
+	}
+	p.init(reflect.TypeOf(slice))
+	if !sorted {
+		p.sort(slice)
+	}
+	if p.rv.Len() == 0 && sorted {
+		p.adopt(reflect.ValueOf(slice))
+		replaced = p.rv.Len()
+		return
+	}
+	rv := reflect.ValueOf(slice)
+	pos := 0
+	for i := 0; i < rv.Len(); i++ {
+		if p.rv.Len() == 0 {
+			p.insertAt(rv.Index(i), 0)
+			replaced++
+			continue
+		}
+		ri := rv.Index(i)
+		v := ri.Interface()
+		pos += p.Search(v, pos)
+		n := pos
+		if pos < p.rv.Len() {
+			e := p.rv.Index(pos).Interface()
+			if p.equal(e, v) {
+				// has v
+				p.unshare()
+				p.rv.Index(pos).Set(ri)
+				continue
+			} else if p.less(e, v) {
+				// less than v, insert after e
+				n++
+			}
+		} else {
+			pos--
+		}
+		replaced++
+		p.insertAt(ri, n)
+		if pos > 0 {
+			pos--
+		}
+	}
+	return
+}
+
+// ReplaceOne ...
+func (p *set) ReplaceOne(v interface{}) (replaced int) {
+	if isInexact(v) {
+		return
+	}
+	p.init(reflect.SliceOf(reflect.TypeOf(v)))
+	if p.rv.Len() == 0 {
+		p.insertAt(reflect.ValueOf(v), 0)
+		replaced++
+		return
+	}
+	pos, found := p.find(v, 0)
+	if found {
+		// has v
+		p.unshare()
+		p.rv.Index(pos).Set(reflect.ValueOf(v))
+		return
+	}
+
+	p.insertAt(reflect.ValueOf(v), pos)
+	replaced++
+	return
+}
+
+func (p *set) EraseOne(v interface{}) (deled int) {
+	if p.Len() == 0 {
+		return
+	}
+
+	pos, found := p.find(v, 0)
+	if !found {
+		return
+	}
+	p.eraseAt(pos)
+	deled = 1
+	return
+}
+
+func (p *set) EraseSlice(slice interface{}, sorted bool) (deled int) {
+	if p.Len() == 0 {
+		return
+	}
+
+	if !sorted {
+		p.sort(slice)
+	}
+	rv := reflect.ValueOf(slice)
+	pos := 0
+	for i := 0; i < rv.Len() && pos < p.rv.Len(); i++ {
+		var found bool
+		if pos, found = p.find(rv.Index(i).Interface(), pos); !found {
+			continue
+		}
+		p.eraseAt(pos)
+		deled++
+	}
+
+	return
+}
+
+// Equal reports whether the set holds the elements of slice, which may also
+// be another Set. An unsorted slice is compared sorted, leaving it as is.
+func (p set) Equal(slice interface{}) bool {
+	var rv reflect.Value
+	if s, ok := slice.(Set); ok {
+		rv = valueOf(s)
+	} else {
+		rv = reflect.ValueOf(slice)
+		if lenOf(rv) > 1 && !sort.SliceIsSorted(slice, p.lessFunc(slice)) {
+			sorted := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+			reflect.Copy(sorted, rv)
+			p.sort(sorted.Interface())
+			rv = sorted
+		}
+	}
+	if p.Len() != lenOf(rv) {
+		return false
+	}
+	for i := 0; i < lenOf(rv); i++ {
+		if !p.equal(p.rv.Index(i).Interface(),
+			rv.Index(i).Interface()) {
+			return false
+		}
+	}
+	return true
+}
+
+// Clone returns a copy whose capacity is its length, whatever the slack of
+// the set.
+func (p set) Clone() Set {
+	return p.CloneWithCap(0)
+}
+
+// CloneWithCap is Clone with room for extra more elements, which erasing
+// does not shrink away.
+func (p set) CloneWithCap(extra int) Set {
+	if !p.rv.IsValid() {
+		return p.new(p.rv)
+	}
+	if extra < 0 {
+		extra = 0
+	}
+	rv := reflect.MakeSlice(p.rv.Type(), p.rv.Len(), p.rv.Len()+extra)
+	reflect.Copy(rv, p.rv)
+	s := p.new(rv)
+	if extra > 0 {
+		s.reserved = rv.Cap()
+	}
+	if p.opt.keyOf != nil {
+		s.keys = append([]interface{}(nil), p.keys...)
+	}
+	if p.opt.trackOrder {
+		s.seq = p.seq
+		s.seqs = append([]uint64(nil), p.seqs...)
+	}
+	return s
+}
+
+func (p *set) Intersection(s Set) Set {
+	if err := CheckComparator(p, s); err != nil {
+		panic(err)
+	}
+	rv := valueOf(s)
+	if !p.rv.IsValid() || !rv.IsValid() {
+		return p.Zero()
+	}
+	pos := 0
+	dst := reflect.Zero(p.rv.Type())
+	for i := 0; i < rv.Len() && pos < p.rv.Len(); i++ {
+		e := rv.Index(i).Interface()
+		pos += p.Search(e, pos)
+
+		if pos == p.rv.Len() {
+			continue
+		}
+		v := p.rv.Index(pos)
+		if p.equal(v.Interface(), e) {
+			dst = reflect.Append(dst, v)
+		}
+	}
+	return p.new(dst)
+}
+
+// IntersectionFunc returns the elements satisfying pred, as if intersecting
+// with the virtual set defined by pred.
+func (p *set) IntersectionFunc(pred func(v interface{}) bool) Set {
+	if !p.rv.IsValid() {
+		return p.Zero()
+	}
+	dst := reflect.Zero(p.rv.Type())
+	for i := 0; i < p.rv.Len(); i++ {
+		v := p.rv.Index(i)
+		if pred(v.Interface()) {
+			dst = reflect.Append(dst, v)
+		}
+	}
+	return p.new(dst)
+}
+
+// setComparator sets the comparators, a nil equal defaulting to
+// reflect.DeepEqual.
+func (p *set) setComparator(less, equal func(s1, s2 interface{}) bool) {
+	if p.opt.coerce {
+		less, equal = coercedComparators(less, equal)
+	}
+	p.less = less
+	p.lessFunc = func(s interface{}) func(i, j int) bool {
+		rv := reflect.ValueOf(s)
+		return func(i, j int) bool {
+			return less(rv.Index(i).Interface(), rv.Index(j).Interface())
+		}
+	}
+	if equal != nil {
+		p.equal = equal
+	} else {
+		p.equal = func(s1, s2 interface{}) bool {
+			ok := reflect.DeepEqual(s1, s2)
+			return ok
+		}
+	}
+}
+
+// new returns a set sharing the comparators of p, backed by rv.
+func (p *set) new(rv reflect.Value) *set {
+	s := &set{
+		lessFunc: p.lessFunc,
+		less:     p.less,
+		equal:    p.equal,
+		opt:      p.opt,
+	}
+	s.adopt(rv)
+	return s
+}
+
+// swap returns the swaper of the current backing slice. The swaper is
+// dropped whenever the backing slice is replaced and rebound on demand.
+func (p *set) swap() func(i, j int) {
+	if p.swaper == nil {
+		p.swaper = reflect.Swapper(p.rv.Interface())
+	}
+	return p.swaper
+}
+
+// adopt replaces the backing slice, treating its current order as the
+// insertion order.
+func (p *set) adopt(rv reflect.Value) {
+	p.rv = rv
+	p.cow = false
+	p.swaper = nil
+	n := lenOf(rv)
+	if p.opt.keyOf != nil {
+		p.keys = make([]interface{}, n)
+		for i := range p.keys {
+			p.keys[i] = p.opt.keyOf(rv.Index(i).Interface())
+		}
+	}
+	if !p.opt.trackOrder {
+		return
+	}
+	p.seqs = make([]uint64, n)
+	for i := range p.seqs {
+		p.seqs[i] = p.seq
+		p.seq++
+	}
+}
+
+// normalize converts a query or inserted value according to the options.
+func (p set) normalize(v interface{}) interface{} {
+	return p.coerce(p.deref(v))
+}
+
+// coerce converts a numeric value, or a slice of them, to the element type
+// when built WithNumericCoercion. A value which does not convert exactly
+// becomes an inexact, and is dropped from a slice.
+func (p set) coerce(v interface{}) interface{} {
+	if !p.opt.coerce || !p.rv.IsValid() {
+		return v
+	}
+	elem := p.rv.Type().Elem()
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Type() == elem || rv.Type() == p.rv.Type() || !isNumeric(elem.Kind()) {
+		return v
+	}
+	if isNumeric(rv.Kind()) {
+		if c, ok := convertExact(rv, elem); ok {
+			return c.Interface()
+		}
+		return inexact{v}
+	}
+	if rv.Kind() == reflect.Slice && isNumeric(rv.Type().Elem().Kind()) {
+		dst := reflect.MakeSlice(p.rv.Type(), 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if c, ok := convertExact(rv.Index(i), elem); ok {
+				dst = reflect.Append(dst, c)
+			}
+		}
+		return dst.Interface()
+	}
+	return v
+}
+
+// inexact is a coerced value the element type cannot represent, such as
+// 2.9 for a set of int. It equals no element, and cannot be inserted.
+type inexact struct {
+	v interface{}
+}
+
+// isInexact reports whether coerce could not convert v.
+func isInexact(v interface{}) bool {
+	_, ok := v.(inexact)
+	return ok
+}
+
+// deref converts a pointer, or a slice of pointers, to the pointed values
+// when built WithDerefInsert. Nil pointers are dropped from slices.
+func (p set) deref(v interface{}) interface{} {
+	if !p.opt.deref {
+		return v
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Ptr && !rv.IsNil():
+		return rv.Elem().Interface()
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Ptr:
+		dst := reflect.MakeSlice(reflect.SliceOf(rv.Type().Elem().Elem()), 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if e := rv.Index(i); !e.IsNil() {
+				dst = reflect.Append(dst, e.Elem())
+			}
+		}
+		return dst.Interface()
+	}
+	return v
+}
+
+// init gives a nil-constructed set its backing slice type on first insert.
+func (p *set) init(typ reflect.Type) {
+	if !p.rv.IsValid() {
+		p.adopt(reflect.Zero(typ))
+	}
+}
+
+// mergeAt resolves inserting v equal to the element at pos according to
+// the conflict policy.
+func (p *set) mergeAt(pos int, v interface{}) {
+	switch {
+	case p.opt.conflict == OnConflictOverwrite:
+	case p.opt.conflict == OnConflictMerge && p.opt.merge != nil:
+		v = p.opt.merge(p.rv.Index(pos).Interface(), v)
+	default:
+		return
+	}
+	p.unshare()
+	e := p.rv.Index(pos)
+	e.Set(reflect.ValueOf(v))
+	if p.opt.keyOf != nil {
+		p.keys[pos] = p.opt.keyOf(e.Interface())
+	}
+}
+
+func (p *set) insertAt(v reflect.Value, pos int) {
+	p.unshare()
+	if p.opt.growth > 1 && p.rv.Len() == p.rv.Cap() {
+		p.grow(1)
+	}
+	p.rv = ReflectInsertAt(p.rv, v, pos)
+	p.swaper = nil
+	if p.opt.trackOrder {
+		p.seqs = append(p.seqs, 0)
+		copy(p.seqs[pos+1:], p.seqs[pos:])
+		p.seqs[pos] = p.seq
+		p.seq++
+	}
+	if p.opt.keyOf != nil {
+		p.keys = append(p.keys, nil)
+		copy(p.keys[pos+1:], p.keys[pos:])
+		p.keys[pos] = p.opt.keyOf(v.Interface())
+	}
+}
+
+func (p *set) eraseAt(pos int) {
+	p.unshare()
+	p.rv = ReflectErase(p.rv, pos)
+	p.swaper = nil
+	if p.opt.trackOrder {
+		copy(p.seqs[pos:], p.seqs[pos+1:])
+		p.seqs = p.seqs[:len(p.seqs)-1]
+	}
+	if p.opt.keyOf != nil {
+		copy(p.keys[pos:], p.keys[pos+1:])
+		p.keys[len(p.keys)-1] = nil
+		p.keys = p.keys[:len(p.keys)-1]
+	}
+	p.shrink()
+}
+
+// shrink reallocates the backing slice to twice its length once the length
+// falls below 1/shrinkFactor of the capacity.
+func (p *set) shrink() {
+	if p.opt.shrinkFactor <= 0 || p.rv.Cap() <= p.reserved || p.rv.Len() >= p.rv.Cap()/p.opt.shrinkFactor {
+		return
+	}
+	capacity := p.rv.Len() * 2
+	if capacity < p.reserved {
+		capacity = p.reserved
+	}
+	p.realloc(capacity)
+}
+
+func (p *set) Zero() Set {
+	if !p.rv.IsValid() {
+		return p.new(p.rv)
+	}
+	return p.new(reflect.Zero(p.rv.Type()))
+}
+
+// New returns a set sharing the comparators of p, holding the elements of
+// slice. A sorted slice is used as the backing slice without copying.
+func (p *set) New(slice interface{}, sorted bool) Set {
+	if sorted {
+		s := p.new(reflect.Value{})
+		s.alias(reflect.ValueOf(slice))
+		return s
+	}
+	s := p.new(reflect.Zero(reflect.TypeOf(slice)))
+	s.Insert(slice)
+	return s
+}
+
+// SetSlice makes the sorted slice the backing slice, without copying.
+func (p *set) SetSlice(slice interface{}) Set {
+	p.alias(reflect.ValueOf(slice))
+	return p
+}
+
+// unshare copies a read-only backing slice before a write.
+func (p *set) unshare() {
+	if p.cow {
+		p.cow = false
+		p.realloc(p.rv.Cap())
+	}
+}
+
+// alias adopts rv, which the caller keeps a reference to.
+func (p *set) alias(rv reflect.Value) {
+	p.adopt(rv)
+	p.aliased = 0
+	if rv.IsValid() && rv.Cap() > 0 {
+		p.aliased = rv.Pointer()
+	}
+}
+
+func (p *set) ReSort() {
+	if p.opt.keyOf != nil {
+		// elements may have been changed through Slice
+		p.adoptKeys()
+	}
+	if st := (sorter{p}); !sort.IsSorted(st) {
+		p.unshare()
+		sort.Sort(st)
+	}
+}
+
+// FirstSeenOrder returns the elements ordered by their first insertion, or
+// nil if the set was not built with TrackInsertionOrder.
+func (p *set) FirstSeenOrder() interface{} {
+	if !p.opt.trackOrder || !p.rv.IsValid() {
+		return nil
+	}
+	index := make([]int, len(p.seqs))
+	for i := range index {
+		index[i] = i
+	}
+	sort.Slice(index, func(i, j int) bool { return p.seqs[index[i]] < p.seqs[index[j]] })
+	rv := reflect.MakeSlice(p.rv.Type(), len(index), len(index))
+	for i, n := range index {
+		rv.Index(i).Set(p.rv.Index(n))
+	}
+	return rv.Interface()
+}
+
+// adoptKeys recomputes the cached keys.
+func (p *set) adoptKeys() {
+	for i := range p.keys {
+		p.keys[i] = p.opt.keyOf(p.rv.Index(i).Interface())
+	}
+}
+
+// sorter sorts the backing slice in place through its swaper, keeping the
+// sequence numbers and cached keys in step.
+type sorter struct {
+	p *set
+}
+
+func (s sorter) Len() int { return s.p.Len() }
+
+func (s sorter) Less(i, j int) bool {
+	if s.p.opt.keyLess != nil {
+		return s.p.opt.keyLess(s.p.keys[i], s.p.keys[j])
+	}
+	return s.p.less(s.p.rv.Index(i).Interface(), s.p.rv.Index(j).Interface())
+}
+
+func (s sorter) Swap(i, j int) {
+	s.p.swap()(i, j)
+	if s.p.opt.trackOrder {
+		s.p.seqs[i], s.p.seqs[j] = s.p.seqs[j], s.p.seqs[i]
+	}
+	if s.p.opt.keyOf != nil {
+		s.p.keys[i], s.p.keys[j] = s.p.keys[j], s.p.keys[i]
+	}
+}
+
+// ReplaceIf overwrites the element equal to v only if cond(existing, v)
+// holds. Nothing is inserted when v is absent.
+func (p *set) ReplaceIf(v interface{}, cond func(existing, incoming interface{}) bool) (replaced bool) {
+	pos := p.Search(v, 0)
+	if pos == p.Len() {
+		return
+	}
+	e := p.rv.Index(pos)
+	if !p.equal(e.Interface(), v) || !cond(e.Interface(), v) {
+		return
+	}
+	p.unshare()
+	p.rv.Index(pos).Set(reflect.ValueOf(v))
+	replaced = true
+	return
+}
+
+// ForEachBatch calls fn with successive batches of up to batchSize elements
+// until fn returns false.
+func (p set) ForEachBatch(batchSize int, fn func(batch []interface{}) bool) {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	for offset := 0; ; offset += batchSize {
+		batch := sliceBatch(p.rv, offset, batchSize)
+		if len(batch) == 0 || !fn(batch) {
+			return
+		}
+	}
+}
+
+func sliceBatch(rv reflect.Value, offset, size int) []interface{} {
+	if !rv.IsValid() || offset >= rv.Len() {
+		return nil
+	}
+	end := offset + size
+	if end > rv.Len() {
+		end = rv.Len()
+	}
+	batch := make([]interface{}, 0, end-offset)
+	for i := offset; i < end; i++ {
+		batch = append(batch, rv.Index(i).Interface())
+	}
+	return batch
+}
+
+// SearchHint is Search starting at hint, returning the absolute index of the
+// first element not less than v. An out of range hint is clamped. nextHint
+// may be passed to the next SearchHint for a larger value.
+func (p set) SearchHint(v interface{}, hint int) (index int, nextHint int) {
+	if hint < 0 {
+		hint = 0
+	} else if hint > p.Len() {
+		hint = p.Len()
+	}
+	index = hint + p.Search(v, hint)
+	nextHint = index
+	if index < p.Len() && p.equal(p.rv.Index(index).Interface(), v) {
+		nextHint++
+	}
+	return
+}
+
+func (p set) Cap() int {
+	if !p.rv.IsValid() {
+		return 0
+	}
+	return p.rv.Cap()
+}
+
+func (p set) ComparatorName() string {
+	return p.opt.name
+}
+
+// Quantile returns the element at rank floor(q*(Len()-1)), q in [0, 1].
+// ok is false for an empty set or q out of range.
+func (p set) Quantile(q float64) (v interface{}, ok bool) {
+	if p.Len() == 0 || !(q >= 0 && q <= 1) {
+		return
+	}
+	return p.rv.Index(int(q * float64(p.Len()-1))).Interface(), true
+}
+
+// Chunks splits the set into up to n contiguous sets whose lengths differ by
+// at most one. The chunks are copies and keep the global order.
+func (p set) Chunks(n int) []Set {
+	if n <= 0 || p.Len() == 0 {
+		return nil
+	}
+	if n > p.Len() {
+		n = p.Len()
+	}
+	chunks := make([]Set, 0, n)
+	size, rest := p.Len()/n, p.Len()%n
+	for i, pos := 0, 0; i < n; i++ {
+		end := pos + size
+		if i < rest {
+			end++
+		}
+		rv := reflect.MakeSlice(p.rv.Type(), end-pos, end-pos)
+		reflect.Copy(rv, p.rv.Slice(pos, end))
+		chunks = append(chunks, p.new(rv))
+		pos = end
+	}
+	return chunks
+}
+
+// Reserve grows the capacity to hold at least n more elements. Erasing then
+// does not shrink the capacity below that.
+func (p *set) Reserve(n int) {
+	if !p.rv.IsValid() {
+		return
+	}
+	p.reserved = p.rv.Len() + n
+	if p.rv.Cap()-p.rv.Len() >= n {
+		return
+	}
+	p.grow(n)
+}
+
+// grow reallocates to hold n more elements, growing the capacity by the
+// factor set WithGrowthFactor if that is larger.
+func (p *set) grow(n int) {
+	capacity := p.rv.Len() + n
+	if p.opt.growth > 1 {
+		if c := int(float64(p.rv.Cap()) * p.opt.growth); c > capacity {
+			capacity = c
+		}
+	}
+	p.realloc(capacity)
+}
+
+// ShrinkToFit reallocates the backing slice to its length, dropping any
+// capacity reserved.
+func (p *set) ShrinkToFit() {
+	p.reserved = 0
+	if !p.rv.IsValid() || p.rv.Cap() == p.rv.Len() {
+		return
+	}
+	p.realloc(p.rv.Len())
+}
+
+func (p *set) realloc(capacity int) {
+	rv := reflect.MakeSlice(p.rv.Type(), p.rv.Len(), capacity)
+	reflect.Copy(rv, p.rv)
+	p.rv = rv
+	p.swaper = nil
+}
+
+// ByteSize returns the bytes held by the backing array, Cap() times the
+// element size. Data referenced by pointer, slice, string or map elements
+// is not counted.
+func (p set) ByteSize() int {
+	if !p.rv.IsValid() {
+		return 0
+	}
+	return p.rv.Cap() * int(p.rv.Type().Elem().Size())
+}
+
+// Get returns the stored element equal to v.
+func (p set) Get(v interface{}) (interface{}, bool) {
+	v = p.normalize(v)
+	pos := p.Search(v, 0)
+	if pos == p.Len() || !p.equal(p.rv.Index(pos).Interface(), v) {
+		return nil, false
+	}
+	return p.rv.Index(pos).Interface(), true
+}
+
+// InsertIfAbsent inserts v unless an equal element is stored or admit
+// rejects it, and reports whether it was inserted.
+func (p *set) InsertIfAbsent(v interface{}) bool {
+	v = p.normalize(v)
+	if p.hasOne(v, 0) || p.admit(v) != nil {
+		return false
+	}
+	return p.InsertOne(v) == 1
+}
+
+// Gaps returns the differences between adjacent elements of a numeric set,
+// or nil for other element kinds.
+func (p set) Gaps() []float64 {
+	if !p.rv.IsValid() || !isNumeric(p.rv.Type().Elem().Kind()) {
+		return nil
+	}
+	gaps := []float64{}
+	for i := 1; i < p.rv.Len(); i++ {
+		gaps = append(gaps, toFloat(p.rv.Index(i))-toFloat(p.rv.Index(i-1)))
+	}
+	return gaps
+}
+
+// Missing returns, as a new slice, the elements of slice that are not in the
+// set. slice is sorted in place if needed.
+func (p set) Missing(slice interface{}) interface{} {
+	p.sort(slice)
+	rv := reflect.ValueOf(slice)
+	dst := reflect.MakeSlice(rv.Type(), 0, 0)
+	pos := 0
+	for i := 0; i < rv.Len(); i++ {
+		v := rv.Index(i)
+		pos += p.Search(v.Interface(), pos)
+		if pos == p.Len() || !p.equal(p.rv.Index(pos).Interface(), v.Interface()) {
+			dst = reflect.Append(dst, v)
+		}
+	}
+	return dst.Interface()
+}
+
+// MergeJoin walks the set and s together in order, calling visit once per
+// distinct element with side -1 if only the set holds it, 1 if only s holds
+// it, and 0 with both stored values if both do.
+func (p set) MergeJoin(s Set, visit func(left, right interface{}, side int)) {
+	rv := valueOf(s)
+	i, j := 0, 0
+	for i < p.Len() && j < lenOf(rv) {
+		a, b := p.rv.Index(i).Interface(), rv.Index(j).Interface()
+		switch {
+		case p.equal(a, b):
+			visit(a, b, 0)
+			i++
+			j++
+		case p.less(a, b):
+			visit(a, nil, -1)
+			i++
+		default:
+			visit(nil, b, 1)
+			j++
+		}
+	}
+	for ; i < p.Len(); i++ {
+		visit(p.rv.Index(i).Interface(), nil, -1)
+	}
+	for ; j < lenOf(rv); j++ {
+		visit(nil, rv.Index(j).Interface(), 1)
+	}
+}
+
+// valueOf returns the backing slice of s, without copying a plain set.
+func valueOf(s Set) reflect.Value {
+	if ps, ok := s.(*set); ok {
+		return ps.rv
+	}
+	return reflect.ValueOf(s.Slice())
+}
+
+func lenOf(rv reflect.Value) int {
+	if !rv.IsValid() {
+		return 0
+	}
+	return rv.Len()
+}
+
+// Snapshot returns a copy of the set.
+func (p set) Snapshot() Set {
+	return p.Clone()
+}
+
+// Since returns the elements added and removed relative to prev, a
+// snapshot of the set taken earlier.
+func (p set) Since(prev Set) (added, removed interface{}) {
+	if !p.rv.IsValid() {
+		rv := valueOf(prev)
+		if !rv.IsValid() {
+			return nil, nil
+		}
+		return reflect.MakeSlice(rv.Type(), 0, 0).Interface(), prev.Clone().Slice()
+	}
+	a := reflect.MakeSlice(p.rv.Type(), 0, 0)
+	r := reflect.MakeSlice(p.rv.Type(), 0, 0)
+	p.MergeJoin(prev, func(left, right interface{}, side int) {
+		switch side {
+		case -1:
+			a = reflect.Append(a, reflect.ValueOf(left))
+		case 1:
+			r = reflect.Append(r, reflect.ValueOf(right))
+		}
+	})
+	return a.Interface(), r.Interface()
+}
+
+// Dedup removes the elements equal to their predecessor, repairing a set
+// whose backing slice was changed through Slice, and returns the number
+// removed.
+func (p *set) Dedup() int {
+	return p.compact(0, p.Len(), func(i int) bool {
+		return i == 0 || !p.equal(p.rv.Index(i-1).Interface(), p.rv.Index(i).Interface())
+	})
+}
+
+// DedupReport is Dedup returning the removed elements as a slice.
+func (p *set) DedupReport() interface{} {
+	if !p.rv.IsValid() {
+		return nil
+	}
+	removed := reflect.MakeSlice(p.rv.Type(), 0, 0)
+	p.compact(0, p.Len(), func(i int) bool {
+		if i == 0 || !p.equal(p.rv.Index(i-1).Interface(), p.rv.Index(i).Interface()) {
+			return true
+		}
+		removed = reflect.Append(removed, p.rv.Index(i))
+		return false
+	})
+	return removed.Interface()
+}
+
+// compact keeps the elements in [lo, hi) for which keep returns true, in a
+// single pass, and returns the number removed. keep sees the indexes of
+// the original slice, visited in order.
+func (p *set) compact(lo, hi int, keep func(i int) bool) int {
+	if p.Len() == 0 {
+		return 0
+	}
+	kept := make([]bool, hi-lo)
+	for i := lo; i < hi; i++ {
+		kept[i-lo] = keep(i)
+	}
+	p.unshare()
+	w := lo
+	for i := lo; i < p.rv.Len(); i++ {
+		if i < hi && !kept[i-lo] {
+			continue
+		}
+		if w != i {
+			p.rv.Index(w).Set(p.rv.Index(i))
+			if p.opt.trackOrder {
+				p.seqs[w] = p.seqs[i]
+			}
+			if p.opt.keyOf != nil {
+				p.keys[w] = p.keys[i]
+			}
+		}
+		w++
+	}
+	removed := p.rv.Len() - w
+	if removed == 0 {
+		return 0
+	}
+	for i := w; i < p.rv.Len(); i++ {
+		p.rv.Index(i).Set(reflect.Zero(p.rv.Type().Elem()))
+	}
+	p.rv = p.rv.Slice(0, w)
+	p.swaper = nil
+	if p.opt.trackOrder {
+		p.seqs = p.seqs[:w]
+	}
+	if p.opt.keyOf != nil {
+		p.keys = p.keys[:w]
+	}
+	p.shrink()
+	return removed
+}
+
+// Checksum returns the hex SHA-256 of the elements formatted by fmt.Sprint,
+// one per line in order. It depends only on the contents of the set.
+func (p set) Checksum() string {
+	h := sha256.New()
+	for i := 0; i < p.Len(); i++ {
+		fmt.Fprintln(h, p.rv.Index(i).Interface())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// DrainRange removes the elements in [lo, hi) and returns them as a slice.
+func (p *set) DrainRange(lo, hi interface{}) interface{} {
+	if !p.rv.IsValid() {
+		return nil
+	}
+	i := p.Search(lo, 0)
+	j := i + p.Search(hi, i)
+	return p.cut(i, j).Interface()
+}
+
+// cut removes the elements in [i, j) with a single shift of the tail, and
+// returns a copy of them.
+func (p *set) cut(i, j int) reflect.Value {
+	out := reflect.MakeSlice(p.rv.Type(), j-i, j-i)
+	if i >= j {
+		return out
+	}
+	reflect.Copy(out, p.rv.Slice(i, j))
+	p.unshare()
+	n := p.rv.Len()
+	reflect.Copy(p.rv.Slice(i, n), p.rv.Slice(j, n))
+	w := n - (j - i)
+	for k := w; k < n; k++ {
+		p.rv.Index(k).Set(reflect.Zero(p.rv.Type().Elem()))
+	}
+	p.rv = p.rv.Slice(0, w)
+	p.swaper = nil
+	if p.opt.trackOrder {
+		copy(p.seqs[i:], p.seqs[j:])
+		p.seqs = p.seqs[:w]
+	}
+	if p.opt.keyOf != nil {
+		copy(p.keys[i:], p.keys[j:])
+		p.keys = p.keys[:w]
+	}
+	p.shrink()
+	return out
+}
+
+// EraseExact erases the element equal to v only if exactEqual(stored, v)
+// also holds, and reports whether it did.
+func (p *set) EraseExact(v interface{}, exactEqual func(a, b interface{}) bool) bool {
+	v = p.normalize(v)
+	pos := p.Search(v, 0)
+	if pos == p.Len() {
+		return false
+	}
+	e := p.rv.Index(pos).Interface()
+	if !p.equal(e, v) || !exactEqual(e, v) {
+		return false
+	}
+	p.eraseAt(pos)
+	return true
+}
+
+// FlushBelow removes the elements less than v and returns them in order,
+// for emitting a prefix once nothing earlier can arrive.
+func (p *set) FlushBelow(v interface{}) interface{} {
+	if !p.rv.IsValid() {
+		return nil
+	}
+	return p.cut(0, p.Search(v, 0)).Interface()
+}
+
+// ToSlice returns a copy of the backing slice, unlike Slice which shares it.
+func (p set) ToSlice() interface{} {
+	if !p.rv.IsValid() {
+		return nil
+	}
+	rv := reflect.MakeSlice(p.rv.Type(), p.rv.Len(), p.rv.Len())
+	reflect.Copy(rv, p.rv)
+	return rv.Interface()
+}
+
+// Rank returns the number of elements less than v, which is the index of v
+// when present.
+func (p set) Rank(v interface{}) int {
+	return p.Search(v, 0)
+}
+
+// ctxCheckInterval is the number of elements ForEachCtx visits between
+// checks of its context.
+const ctxCheckInterval = 64
+
+// ForEachCtx calls fn with each element in order until fn returns false or
+// ctx is done, in which case it returns ctx.Err().
+func (p set) ForEachCtx(ctx context.Context, fn func(i int, v interface{}) bool) error {
+	for i := 0; i < p.Len(); i++ {
+		if i%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if !fn(i, p.rv.Index(i).Interface()) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// IsSubset reports whether every element is in s, walking both in order.
+func (p set) IsSubset(s Set) bool {
+	rv := valueOf(s)
+	j := 0
+	for i := 0; i < p.Len(); i++ {
+		a := p.rv.Index(i).Interface()
+		for j < lenOf(rv) && p.less(rv.Index(j).Interface(), a) {
+			j++
+		}
+		if j == lenOf(rv) || !p.equal(a, rv.Index(j).Interface()) {
+			return false
+		}
+		j++
+	}
+	return true
+}
+
+// IsProperSubset reports whether p is a subset of s and s has an element
+// which p lacks.
+func (p set) IsProperSubset(s Set) bool {
+	return p.Len() < s.Len() && p.IsSubset(s)
+}
+
+// RefreshOrder re-sorts and re-dedups the set under the current comparators.
+// A set whose less depends on external state, like a configurable priority,
+// is mis-sorted once that state changes, and must be refreshed before any
+// other use.
+func (p *set) RefreshOrder() {
+	p.ReSort()
+	p.Dedup()
+}
+
+// Validate returns a *MutationError for the first element changed since it
+// was inserted, when built WithImmutabilityCheck, or ErrUnsorted if the
+// elements are not in strictly increasing order.
+func (p set) Validate() error {
+	if p.opt.immutable {
+		for i, was := range p.keys {
+			if now := p.rv.Index(i).Interface(); !p.equal(was, now) {
+				return &MutationError{Index: i, Was: was, Now: now}
+			}
+		}
+	}
+	for i := 1; i < p.Len(); i++ {
+		if !p.less(p.rv.Index(i-1).Interface(), p.rv.Index(i).Interface()) {
+			return ErrUnsorted
+		}
+	}
+	return nil
+}
+
+// IsSorted reports whether Validate finds no error.
+func (p set) IsSorted() bool {
+	return p.Validate() == nil
+}
+
+// Toggle erases v if present and inserts it otherwise, unless admit rejects
+// it, returning whether v is present afterwards.
+func (p *set) Toggle(v interface{}) (nowPresent bool) {
+	v = p.normalize(v)
+	if p.EraseOne(v) == 1 || p.admit(v) != nil {
+		return false
+	}
+	p.InsertOne(v)
+	return true
+}
+
+// UnionCount returns the length of the union with s without building it.
+func (p set) UnionCount(s Set) (n int) {
+	rv := valueOf(s)
+	i, j := 0, 0
+	for i < p.Len() && j < lenOf(rv) {
+		a, b := p.rv.Index(i).Interface(), rv.Index(j).Interface()
+		switch {
+		case p.equal(a, b):
+			i++
+			j++
+		case p.less(a, b):
+			i++
+		default:
+			j++
+		}
+		n++
+	}
+	return n + p.Len() - i + lenOf(rv) - j
+}
+
+// InsertE is Insert, one element at a time, stopping at the first element
+// which cannot be inserted: ErrNilElement for a nil element, which Insert
+// skips, ErrConflict for one conflicting under
+// OnConflictError, or an error of admit. The elements before it stay
+// inserted.
+func (p *set) InsertE(v ...interface{}) (added int, err error) {
+	err = p.each(v, func(e interface{}) error {
+		if err := p.check(e); err != nil {
+			return err
+		}
+		added += p.InsertOne(e)
+		return nil
+	})
+	return
+}
+
+// each calls fn with each normalized element of the arguments, which may be
+// slices of elements, until fn fails.
+func (p *set) each(args []interface{}, fn func(e interface{}) error) error {
+	for _, arg := range args {
+		arg = p.normalize(arg)
+		rv := reflect.ValueOf(arg)
+		if rv.Kind() != reflect.Slice {
+			if err := fn(arg); err != nil {
+				return err
+			}
+			continue
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := fn(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ErrInexact ...
+var ErrInexact = errors.New("set: value not exactly representable as the element type")
+
+// ErrNilElement ...
+var ErrNilElement = errors.New("set: nil element")
+
+// check returns why InsertE cannot insert v.
+func (p *set) check(v interface{}) error {
+	if isNil(v) {
+		return ErrNilElement
+	}
+	if p.opt.conflict == OnConflictError && p.Len() > 0 && p.hasOne(v, 0) {
+		return ErrConflict
+	}
+	return p.admit(v)
+}
+
+// guarded reports whether admit may reject elements.
+func (p *set) guarded() bool {
+	return p.opt.guard != nil || p.opt.min != nil || p.opt.max != nil
+}
+
+// admit returns ErrOutOfBounds for v outside WithBounds, or the error of the
+// guard given to WithInsertGuard.
+func (p *set) admit(v interface{}) error {
+	if isInexact(v) {
+		return ErrInexact
+	}
+	if p.opt.min != nil && p.less(v, p.opt.min) || p.opt.max != nil && p.less(p.opt.max, v) {
+		return ErrOutOfBounds
+	}
+	if p.opt.guard != nil {
+		return p.opt.guard(p, v)
+	}
+	return nil
+}
+
+// dropNil returns the slice rv without its nil elements, copying it only if
+// it has any.
+func dropNil(rv reflect.Value) reflect.Value {
+	switch rv.Type().Elem().Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+	default:
+		return rv
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if !isNil(rv.Index(i).Interface()) {
+			continue
+		}
+		dst := reflect.AppendSlice(reflect.MakeSlice(rv.Type(), 0, rv.Len()-1), rv.Slice(0, i))
+		for i++; i < rv.Len(); i++ {
+			if e := rv.Index(i); !isNil(e.Interface()) {
+				dst = reflect.Append(dst, e)
+			}
+		}
+		return dst
+	}
+	return rv
+}
+
+// isNil reports whether v is nil, or a nil pointer, interface, map, slice,
+// func or chan, which comparators usually cannot handle.
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// SymmetricDifferenceIter returns an iterator over the elements in exactly
+// one of p and s, in order, telling whether each is from p. ok is false once
+// the walk is done. Both sets must not change during the walk.
+func (p set) SymmetricDifferenceIter(s Set) func() (v interface{}, fromLeft bool, ok bool) {
+	left, right := p.rv, valueOf(s)
+	i, j := 0, 0
+	return func() (interface{}, bool, bool) {
+		for i < lenOf(left) && j < lenOf(right) {
+			a, b := left.Index(i).Interface(), right.Index(j).Interface()
+			switch {
+			case p.equal(a, b):
+				i++
+				j++
+			case p.less(a, b):
+				i++
+				return a, true, true
+			default:
+				j++
+				return b, false, true
+			}
+		}
+		if i < lenOf(left) {
+			i++
+			return left.Index(i - 1).Interface(), true, true
+		}
+		if j < lenOf(right) {
+			j++
+			return right.Index(j - 1).Interface(), false, true
+		}
+		return nil, false, false
+	}
+}
+
+// CountPresent returns the number of distinct elements of slice that are in
+// the set, so duplicates in slice count once. slice is sorted in place if
+// needed.
+func (p set) CountPresent(slice interface{}) (n int) {
+	p.sort(slice)
+	rv := reflect.ValueOf(slice)
+	pos := 0
+	for i := 0; i < rv.Len() && pos < p.Len(); i++ {
+		v := rv.Index(i).Interface()
+		if i > 0 && p.equal(rv.Index(i-1).Interface(), v) {
+			continue
+		}
+		pos += p.Search(v, pos)
+		if pos < p.Len() && p.equal(p.rv.Index(pos).Interface(), v) {
+			n++
+		}
+	}
+	return
+}
+
+// Update calls fn with a clone of the set, and takes the clone's contents if
+// fn returns nil, so that a failing fn leaves the set unchanged.
+func (p *set) Update(fn func(s Set) error) error {
+	s := p.Clone().(*set)
+	if err := fn(s); err != nil {
+		return err
+	}
+	*p = *s
+	return nil
+}
+
+// IsAliased reports whether the backing array is still that of a slice
+// adopted without copying, by NewFromSortedNoCopy, SetSlice or New with
+// sorted, so that changes to it reach the caller's slice and back.
+func (p set) IsAliased() bool {
+	return p.aliased != 0 && p.rv.IsValid() && p.rv.Cap() > 0 && p.rv.Pointer() == p.aliased
+}
+
+// WriteUnion writes the union with s to w in order, one formatted element
+// per line, without building it.
+func (p set) WriteUnion(w io.Writer, s Set, format func(v interface{}) string) error {
+	rv := valueOf(s)
+	write := func(v interface{}) error {
+		_, err := io.WriteString(w, format(v)+"\n")
+		return err
+	}
+	i, j := 0, 0
+	for i < p.Len() || j < lenOf(rv) {
+		var v interface{}
+		switch {
+		case j == lenOf(rv):
+			v = p.rv.Index(i).Interface()
+			i++
+		case i == p.Len():
+			v = rv.Index(j).Interface()
+			j++
+		default:
+			a, b := p.rv.Index(i).Interface(), rv.Index(j).Interface()
+			switch {
+			case p.equal(a, b):
+				v = a
+				i++
+				j++
+			case p.less(a, b):
+				v = a
+				i++
+			default:
+				v = b
+				j++
+			}
+		}
+		if err := write(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LongestRun returns the first longest run of consecutive elements
+// satisfying pred, or start -1 if none does.
+func (p set) LongestRun(pred func(v interface{}) bool) (start, length int) {
+	start = -1
+	cur := 0
+	for i := 0; i < p.Len(); i++ {
+		if !pred(p.rv.Index(i).Interface()) {
+			cur = 0
+			continue
+		}
+		cur++
+		if cur > length {
+			start, length = i-cur+1, cur
+		}
+	}
+	return
+}
+
+// Reader returns a read handle to a copy of the set.
+func (p set) Reader() SetReader {
+	return &reader{s: p.Clone()}
+}
+
+// EraseRangeIf erases the elements in [lo, hi) satisfying pred in a single
+// pass, and returns the number erased.
+func (p *set) EraseRangeIf(lo, hi interface{}, pred func(v interface{}) bool) int {
+	if !p.rv.IsValid() {
+		return 0
+	}
+	i := p.Search(lo, 0)
+	j := i + p.Search(hi, i)
+	return p.compact(i, j, func(k int) bool {
+		return !pred(p.rv.Index(k).Interface())
+	})
+}
+
+// Neighbors returns the greatest element not greater than v and the least
+// element not less than v, using a single search. An element equal to v is
+// returned as both.
+func (p set) Neighbors(v interface{}) (below, above interface{}, hasBelow, hasAbove bool) {
+	i := p.Search(v, 0)
+	if i < p.Len() {
+		above, hasAbove = p.rv.Index(i).Interface(), true
+		if p.equal(above, p.normalize(v)) {
+			return above, above, true, true
+		}
+	}
+	if i > 0 {
+		below, hasBelow = p.rv.Index(i-1).Interface(), true
+	}
+	return
+}
+
+// SearchGallop is SearchHint searching outward from hint in steps doubling
+// in size, then within the last step, so that looking up a value k elements
+// away from hint takes O(log k) comparisons. v may be on either side of hint.
+func (p set) SearchGallop(v interface{}, hint int) (index int, nextHint int) {
+	v = p.normalize(v)
+	n := p.Len()
+	if hint < 0 {
+		hint = 0
+	} else if hint > n {
+		hint = n
+	}
+	less := func(i int) bool { return p.less(p.rv.Index(i).Interface(), v) }
+	lo, hi := 0, 0
+	if hint < n && less(hint) {
+		// the index is in (hint, n]
+		lo, hi = hint+1, hint+1
+		for step := 1; hi < n && less(hi); step *= 2 {
+			lo, hi = hi+1, hint+2*step
+		}
+		if hi > n {
+			hi = n
+		}
+	} else {
+		// the index is in [0, hint]
+		lo, hi = hint-1, hint
+		for step := 1; lo >= 0 && !less(lo); step *= 2 {
+			hi, lo = lo, hint-2*step
+		}
+		lo++
+		if lo < 0 {
+			lo = 0
+		}
+	}
+	index = lo + sort.Search(hi-lo, func(i int) bool { return !less(lo + i) })
+	nextHint = index
+	if index < n && p.equal(p.rv.Index(index).Interface(), v) {
+		nextHint++
+	}
+	return
+}
+
+// SetComparator replaces the comparators, a nil equal defaulting to
+// reflect.DeepEqual, then re-sorts and re-dedups the set. The comparator
+// name and any cached keys of NewCachedKey are dropped.
+func (p *set) SetComparator(less, equal func(s1, s2 interface{}) bool) {
+	p.replaceComparator(less, equal)
+	p.RefreshOrder()
+}
+
+// replaceComparator sets the comparators of SetComparator and Reset. The
+// options are copied as they are shared with derived sets.
+func (p *set) replaceComparator(less, equal func(s1, s2 interface{}) bool) {
+	opt := *p.opt
+	opt.name = ""
+	opt.unkeyed = nil
+	if opt.keyLess != nil {
+		opt.keyOf, opt.keyLess, opt.keyEqual = nil, nil, false
+		p.keys = nil
+	}
+	p.opt = &opt
+	p.setComparator(less, equal)
+}
+
+// UnionMerge returns the union with s, storing combine(a, b) for an element
+// a of the set equal to an element b of s.
+func (p *set) UnionMerge(s Set, combine func(a, b interface{}) interface{}) Set {
+	if err := CheckComparator(p, s); err != nil {
+		panic(err)
+	}
+	rv := valueOf(s)
+	if !p.rv.IsValid() && !rv.IsValid() {
+		return p.Zero()
+	}
+	var typ reflect.Type
+	if p.rv.IsValid() {
+		typ = p.rv.Type()
+	} else {
+		typ = rv.Type()
+	}
+	dst := reflect.MakeSlice(typ, 0, p.Len()+lenOf(rv))
+	i, j := 0, 0
+	for i < p.Len() && j < lenOf(rv) {
+		a, b := p.rv.Index(i), rv.Index(j)
+		switch {
+		case p.equal(a.Interface(), b.Interface()):
+			dst = reflect.Append(dst, reflect.ValueOf(combine(a.Interface(), b.Interface())))
+			i++
+			j++
+		case p.less(a.Interface(), b.Interface()):
+			dst = reflect.Append(dst, a)
+			i++
+		default:
+			dst = reflect.Append(dst, b)
+			j++
+		}
+	}
+	if i < p.Len() {
+		dst = reflect.AppendSlice(dst, p.rv.Slice(i, p.Len()))
+	}
+	if j < lenOf(rv) {
+		dst = reflect.AppendSlice(dst, rv.Slice(j, rv.Len()))
+	}
+	return p.new(dst)
+}
+
+// FilterAll returns the elements satisfying every pred in a single pass,
+// trying the preds of an element in order until one fails.
+func (p *set) FilterAll(preds ...func(v interface{}) bool) Set {
+	return p.IntersectionFunc(func(v interface{}) bool {
+		for _, pred := range preds {
+			if !pred(v) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// WouldAdd reports whether inserting v would add it, v being absent and
+// allowed by WithBounds and WithInsertGuard, without changing the set.
+func (p *set) WouldAdd(v interface{}) bool {
+	v = p.normalize(v)
+	return !p.Has(v, 0) && p.admit(v) == nil
+}
+
+// Reset replaces the comparators as SetComparator does and the elements
+// with those of slice, which may be of another type. slice is left as is.
+func (p *set) Reset(slice interface{}, less, equal func(s1, s2 interface{}) bool) {
+	p.replaceComparator(less, equal)
+	p.adopt(reflect.Value{})
+	if slice == nil {
+		return
+	}
+	rv := reflect.ValueOf(slice)
+	arr := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+	reflect.Copy(arr, rv)
+	p.init(rv.Type())
+	p.Insert(arr.Interface())
+}
+
+// ForEachPair calls fn with each pair of adjacent elements in order until fn
+// returns false.
+func (p set) ForEachPair(fn func(cur, next interface{}) bool) {
+	for i := 1; i < p.Len(); i++ {
+		if !fn(p.rv.Index(i-1).Interface(), p.rv.Index(i).Interface()) {
+			return
+		}
+	}
+}
+
+// ContainsSet reports whether every element of s is in the set, searching
+// the set for each element of s in turn, from the position of the last.
+func (p set) ContainsSet(s Set) bool {
+	rv := valueOf(s)
+	if lenOf(rv) > p.Len() {
+		return false
+	}
+	pos := 0
+	for i := 0; i < lenOf(rv); i++ {
+		e := rv.Index(i).Interface()
+		pos += p.Search(e, pos)
+		if pos == p.Len() || !p.equal(p.rv.Index(pos).Interface(), e) {
+			return false
+		}
+	}
+	return true
+}
+
+// Quantiles returns, as a slice, the n-1 elements splitting the set into n
+// buckets of about equal length, the k-th one being at nearest rank
+// ceil(k*Len()/n). It is empty for n < 2 or an empty set.
+func (p set) Quantiles(n int) interface{} {
+	if !p.rv.IsValid() {
+		return nil
+	}
+	if n < 2 || p.Len() == 0 {
+		return reflect.MakeSlice(p.rv.Type(), 0, 0).Interface()
+	}
+	bounds := reflect.MakeSlice(p.rv.Type(), n-1, n-1)
+	for k := 1; k < n; k++ {
+		rank := (k*p.Len() + n - 1) / n
+		if rank > 0 {
+			rank--
+		}
+		bounds.Index(k - 1).Set(p.rv.Index(rank))
+	}
+	return bounds.Interface()
 }
 
-var _ Set = (*set)(nil)
-
-func (p set) Len() int {
-	return p.rv.Len()
+// BatchInsertRCU is Insert(slice), for sets safe for concurrent use.
+func (p *set) BatchInsertRCU(slice interface{}) int {
+	return p.Insert(slice)
 }
 
-func (p set) Slice() interface{} {
-	return p.rv.Interface()
+// Stream sends the elements in order on the returned channel, closed after
+// the last one. It streams a copy taken by the call, so the set may change
+// meanwhile. The channel must be drained to end the sending goroutine.
+func (p set) Stream() <-chan interface{} {
+	ch := make(chan interface{})
+	rv := reflect.ValueOf(p.ToSlice())
+	go func() {
+		for i := 0; i < lenOf(rv); i++ {
+			ch <- rv.Index(i).Interface()
+		}
+		close(ch)
+	}()
+	return ch
 }
 
-func (p set) Search(v interface{}, pos int) int {
-	return sort.Search(p.rv.Len()-pos, func(i int) bool {
-		return !p.less(p.rv.Index(pos+i).Interface(), v)
-	})
+// OverlapRange returns the intersection [lo, hi] of the value ranges of the
+// set and s, from their first and last elements alone. ok is false if the
+// ranges are disjoint or either set is empty.
+func (p set) OverlapRange(s Set) (lo, hi interface{}, ok bool) {
+	rv := valueOf(s)
+	if p.Len() == 0 || lenOf(rv) == 0 {
+		return
+	}
+	lo, hi = p.rv.Index(0).Interface(), p.rv.Index(p.Len()-1).Interface()
+	if min := rv.Index(0).Interface(); p.less(lo, min) {
+		lo = min
+	}
+	if max := rv.Index(rv.Len() - 1).Interface(); p.less(max, hi) {
+		hi = max
+	}
+	if p.less(hi, lo) {
+		return nil, nil, false
+	}
+	return lo, hi, true
 }
 
-func (p set) hasOne(v interface{}, pos int) bool {
-	n := p.Search(v, pos)
-	if pos+n == p.rv.Len() || !p.equal(p.rv.Index(pos+n).Interface(), v) {
+// CoalesceBy replaces each run of consecutive elements for which same holds
+// pairwise by the merge of the run, folded from its first element, and
+// returns the number of elements removed. merge must keep the merged element
+// between the neighbors of the run.
+func (p *set) CoalesceBy(same func(a, b interface{}) bool, merge func(a, b interface{}) interface{}) int {
+	p.unshare()
+	last := -1
+	return p.compact(0, p.Len(), func(i int) bool {
+		v := p.rv.Index(i).Interface()
+		if last < 0 || !same(p.rv.Index(last).Interface(), v) {
+			last = i
+			return true
+		}
+		e := p.rv.Index(last)
+		e.Set(reflect.ValueOf(merge(e.Interface(), v)))
+		if p.opt.keyOf != nil {
+			p.keys[last] = p.opt.keyOf(e.Interface())
+		}
 		return false
-	}
-	return true
+	})
 }
 
-func (p set) hasSlice(slice interface{}, pos int) bool {
-	p.sort(slice)
-	rv := reflect.ValueOf(slice)
-	if rv.Len() > p.rv.Len() {
-		return false
-	}
-	if p.rv.Len() == 0 {
-		return true
+// AtMany returns, as a slice, the elements at indices in the order given,
+// skipping the indices out of range.
+func (p set) AtMany(indices []int) interface{} {
+	if !p.rv.IsValid() {
+		return nil
 	}
-
-	for i := 0; i < rv.Len() && pos < p.rv.Len(); i++ {
-		v := rv.Index(i).Interface()
-		pos += p.Search(v, pos)
-		if pos == p.rv.Len() || !p.equal(p.rv.Index(pos).Interface(), v) {
-			return false
+	elems := reflect.MakeSlice(p.rv.Type(), 0, len(indices))
+	for _, i := range indices {
+		if i >= 0 && i < p.Len() {
+			elems = reflect.Append(elems, p.rv.Index(i))
 		}
 	}
-	return true
+	return elems.Interface()
 }
 
-func (p set) Has(v interface{}, pos int) bool {
-	if reflect.TypeOf(v) == p.rv.Type() {
-		return p.hasSlice(v, pos)
-	}
-	return p.hasOne(v, pos)
+// CloneAs returns a copy of the set under other comparators, as if by Clone
+// and SetComparator, leaving the set unchanged.
+func (p set) CloneAs(less, equal func(s1, s2 interface{}) bool) Set {
+	s := p.Clone().(*set)
+	s.SetComparator(less, equal)
+	return s
 }
 
-func (p *set) Insert(v ...interface{}) (added int) {
-	for _, arg := range v {
-		rv := reflect.ValueOf(arg)
-		if rv.Type().Kind() == reflect.Slice {
-			added += p.InsertSlice(arg, false)
-			continue
+// FirstDiff returns the first index where the set and s differ, with the
+// element of each there; a side that is too short reports nil. equal is true
+// when the sets hold the same elements.
+func (p set) FirstDiff(s Set) (index int, a, b interface{}, equal bool) {
+	rv := valueOf(s)
+	n, m := p.Len(), lenOf(rv)
+	for ; index < n && index < m; index++ {
+		a, b = p.rv.Index(index).Interface(), rv.Index(index).Interface()
+		if !p.equal(a, b) {
+			return
 		}
-		added += p.InsertOne(arg)
 	}
+	a, b = nil, nil
+	if index < n {
+		a = p.rv.Index(index).Interface()
+	}
+	if index < m {
+		b = rv.Index(index).Interface()
+	}
+	equal = n == m
 	return
 }
 
-func (p *set) Replace(v ...interface{}) (replaced int) {
-	for _, arg := range v {
-		rv := reflect.ValueOf(arg)
-		if rv.Type().Kind() == reflect.Slice {
-			replaced += p.ReplaceSlice(arg, false)
-			continue
-		}
-		replaced += p.ReplaceOne(arg)
+// SplitAt removes the elements not less than pivot and returns them as a new
+// set with the same comparators, keeping the lower part in the set.
+func (p *set) SplitAt(pivot interface{}) (greaterOrEqual Set) {
+	if !p.rv.IsValid() {
+		return p.new(p.rv)
 	}
-	return
+	return p.new(p.cut(p.Search(pivot, 0), p.rv.Len()))
 }
 
-func (p *set) Erase(v ...interface{}) (added int) {
-	for _, arg := range v {
-		rv := reflect.ValueOf(arg)
-		if rv.Type() == p.rv.Type() {
-			added += p.EraseSlice(arg, false)
-			continue
+// Pairs calls fn with each pair of elements i < j in order until fn returns
+// false, without building the pairs.
+func (p set) Pairs(fn func(a, b interface{}) bool) {
+	for i := 0; i < p.Len(); i++ {
+		a := p.rv.Index(i).Interface()
+		for j := i + 1; j < p.Len(); j++ {
+			if !fn(a, p.rv.Index(j).Interface()) {
+				return
+			}
 		}
-		added += p.EraseOne(arg)
 	}
-	return
 }
 
-func (p set) sort(slice interface{}) {
-	lf := p.lessFunc(slice)
-	if !sort.SliceIsSorted(slice, lf) {
-		sort.Slice(slice, lf)
+// ConvertElem returns a new set of the elements mapped by conv, under the
+// new comparators and without the options of the set. The element type is
+// that of the first converted value; an empty set converts to an empty set
+// with no backing slice.
+func (p set) ConvertElem(newLess, newEqual func(a, b interface{}) bool, conv func(v interface{}) interface{}) Set {
+	if p.Len() == 0 {
+		return NewWithOptions(nil, newLess, newEqual)
 	}
+	first := reflect.ValueOf(conv(p.rv.Index(0).Interface()))
+	dst := reflect.MakeSlice(reflect.SliceOf(first.Type()), 1, p.Len())
+	dst.Index(0).Set(first)
+	for i := 1; i < p.Len(); i++ {
+		dst = reflect.Append(dst, reflect.ValueOf(conv(p.rv.Index(i).Interface())))
+	}
+	return NewWithOptions(dst.Interface(), newLess, newEqual)
 }
 
-func (p *set) InsertSlice(slice interface{}, sorted bool) (added int) {
-	if !sorted {
-		p.sort(slice)
+// Repair restores a set damaged by unsynchronized use or writes through
+// Slice, as a best effort: nil elements are dropped, the rest re-sorted and
+// deduplicated. It returns the number of elements dropped or moved, and the
+// Validate error if the set is still invalid afterwards.
+func (p *set) Repair() (fixed int, err error) {
+	if !p.rv.IsValid() {
+		return 0, nil
 	}
-	if p.rv.Len() == 0 && sorted {
-		p.rv = reflect.ValueOf(slice)
-		added = p.rv.Len()
-		return
+	if (p.opt.keyOf != nil && len(p.keys) != p.rv.Len()) ||
+		(p.opt.trackOrder && len(p.seqs) != p.rv.Len()) {
+		// torn parallel slices can not be matched up, start over
+		p.adopt(p.rv)
+		fixed++
 	}
-	rv := reflect.ValueOf(slice)
-	pos := 0
-	for i := 0; i < rv.Len(); i++ {
-		if p.rv.Len() == 0 {
-			p.rv = reflect.Append(p.rv, rv.Index(i))
-			added++
-			continue
-		}
-		ri := rv.Index(i)
-		v := ri.Interface()
-		pos += p.Search(v, pos)
-		n := pos
-		if pos < p.rv.Len() {
-			e := p.rv.Index(pos).Interface()
-			if p.equal(e, v) {
-				// has v
-				continue
-			} else if p.less(e, v) {
-				// less than v, insert after e
-				n++
-			}
-		} else {
-			pos--
+	fixed += p.compact(0, p.Len(), func(i int) bool {
+		return !isNil(p.rv.Index(i).Interface())
+	})
+	before := reflect.MakeSlice(p.rv.Type(), p.rv.Len(), p.rv.Len())
+	reflect.Copy(before, p.rv)
+	p.ReSort()
+	for i := 0; i < before.Len(); i++ {
+		if !p.equal(before.Index(i).Interface(), p.rv.Index(i).Interface()) {
+			fixed++
 		}
-		added++
-		p.rv = ReflectInsertAt(p.rv, ri, n)
-		if pos > 0 {
-			pos--
+	}
+	fixed += p.Dedup()
+	return fixed, p.Validate()
+}
+
+// OrderingInfo returns what a loader needs to pick a constructor: the
+// element kind, reflect.Invalid with no backing slice, and the comparator
+// name. descending is inferred from the first and last elements for numeric
+// and string kinds, and is false otherwise.
+func (p set) OrderingInfo() (elemKind reflect.Kind, descending bool, comparatorName string) {
+	comparatorName = p.opt.name
+	if !p.rv.IsValid() {
+		return reflect.Invalid, false, comparatorName
+	}
+	elemKind = p.rv.Type().Elem().Kind()
+	if n := p.rv.Len(); n > 1 {
+		first, last := p.rv.Index(0), p.rv.Index(n-1)
+		switch {
+		case isNumeric(elemKind):
+			descending = toFloat(first) > toFloat(last)
+		case elemKind == reflect.String:
+			descending = first.String() > last.String()
 		}
 	}
 	return
 }
 
-func (p *set) InsertOne(v interface{}) (added int) {
-	if p.rv.Len() == 0 {
-		p.rv = reflect.Append(p.rv, reflect.ValueOf(v))
-		added++
-		return
+// NewSince returns, in order, the elements first inserted after the set was
+// at version, and the current version, which counts the insertions so far.
+// Passing the returned version to the next call yields only what is new.
+// Both are zero if the set was not built with TrackInsertionOrder.
+func (p set) NewSince(version uint64) (elements interface{}, currentVersion uint64) {
+	if !p.opt.trackOrder || !p.rv.IsValid() {
+		return nil, p.seq
 	}
-	pos := p.Search(v, 0)
-	n := pos
-	if pos < p.rv.Len() {
-		e := p.rv.Index(pos).Interface()
-		if p.equal(e, v) {
-			// has v
-			return
-		} else if p.less(e, v) {
-			// less than v, insert after e
-			n++
+	rv := reflect.MakeSlice(p.rv.Type(), 0, 0)
+	for i, seq := range p.seqs {
+		if seq >= version {
+			rv = reflect.Append(rv, p.rv.Index(i))
 		}
-	} else {
-		pos--
 	}
-
-	p.rv = ReflectInsertAt(p.rv, reflect.ValueOf(v), n)
-	added++
-	return
+	return rv.Interface(), p.seq
 }
 
-func (p *set) ReplaceSlice(slice interface{}, sorted bool) (replaced int) {
-	if !sorted {
-		p.sort(slice)
+// Diff returns, as sorted slices, the elements of s missing from the set
+// and those of the set missing from s, so that ApplyDiff(added, removed)
+// brings the set to s. Both are nil if neither set has a backing slice.
+func (p set) Diff(s Set) (added, removed interface{}) {
+	rv := p.rv
+	if !rv.IsValid() {
+		rv = valueOf(s)
 	}
-	if p.rv.Len() == 0 && sorted {
-		p.rv = reflect.ValueOf(slice)
-		replaced = p.rv.Len()
-		return
+	if !rv.IsValid() {
+		return nil, nil
 	}
-	rv := reflect.ValueOf(slice)
-	pos := 0
-	for i := 0; i < rv.Len(); i++ {
-		if p.rv.Len() == 0 {
-			p.rv = reflect.Append(p.rv, rv.Index(i))
-			replaced++
-			continue
-		}
-		ri := rv.Index(i)
-		v := ri.Interface()
-		pos += p.Search(v, pos)
-		n := pos
-		if pos < p.rv.Len() {
-			e := p.rv.Index(pos).Interface()
-			if p.equal(e, v) {
-				// has v
-				p.rv.Index(pos).Set(ri)
-				continue
-			} else if p.less(e, v) {
-				// less than v, insert after e
-				n++
-			}
+	adds := reflect.MakeSlice(rv.Type(), 0, 0)
+	dels := adds
+	next := p.SymmetricDifferenceIter(s)
+	for v, fromLeft, ok := next(); ok; v, fromLeft, ok = next() {
+		if fromLeft {
+			dels = reflect.Append(dels, reflect.ValueOf(v))
 		} else {
-			pos--
-		}
-		replaced++
-		p.rv = ReflectInsertAt(p.rv, ri, n)
-		if pos > 0 {
-			pos--
+			adds = reflect.Append(adds, reflect.ValueOf(v))
 		}
 	}
-	return
+	return adds.Interface(), dels.Interface()
 }
 
-// ReplaceOne ...
-func (p *set) ReplaceOne(v interface{}) (replaced int) {
-	if p.rv.Len() == 0 {
-		p.rv = reflect.Append(p.rv, reflect.ValueOf(v))
-		replaced++
-		return
+// ApplyDiff erases the removed slice then inserts the added one, so an
+// element in both ends up present, and returns the change in length.
+// Either may be nil.
+func (p *set) ApplyDiff(added, removed interface{}) (net int) {
+	if removed != nil {
+		net -= p.Erase(removed)
 	}
-	pos := p.Search(v, 0)
-	n := pos
-	if pos < p.rv.Len() {
-		e := p.rv.Index(pos).Interface()
-		if p.equal(e, v) {
-			// has v
-			p.rv.Index(pos).Set(reflect.ValueOf(v))
-			return
-		} else if p.less(e, v) {
-			// less than v, insert after e
-			n++
-		}
-	} else {
-		pos--
+	if added != nil {
+		net += p.Insert(added)
 	}
-
-	p.rv = ReflectInsertAt(p.rv, reflect.ValueOf(v), n)
-	replaced++
 	return
 }
 
-func (p *set) EraseOne(v interface{}) (deled int) {
-	if p.rv.Len() == 0 {
+// Reindex orders the set by keyOf of each element against the set as it
+// is, for orders that depend on the whole set such as distance from the
+// median. Keys are cached as with NewCachedKey and ties keep the order
+// before the first Reindex. Later inserts are keyed against the set at the
+// time, so call Reindex again after changes. It computes n keys, then sorts
+// in O(n log n). The comparator name is dropped.
+func (p *set) Reindex(keyOf func(s Set, v interface{}) float64) {
+	opt := *p.opt
+	if opt.unkeyed == nil {
+		opt.unkeyed = p.less
+	}
+	base := opt.unkeyed
+	opt.name = ""
+	opt.immutable = false
+	opt.keyEqual = false
+	opt.keyOf = func(v interface{}) interface{} {
+		return reindexKey{keyOf(p, v), v}
+	}
+	opt.keyLess = func(a, b interface{}) bool {
+		ka, kb := a.(reindexKey), b.(reindexKey)
+		if ka.key != kb.key {
+			return ka.key < kb.key
+		}
+		return base(ka.v, kb.v)
+	}
+	p.opt = &opt
+	p.setComparator(func(s1, s2 interface{}) bool {
+		return opt.keyLess(opt.keyOf(s1), opt.keyOf(s2))
+	}, p.equal)
+	if !p.rv.IsValid() {
 		return
 	}
+	if len(p.keys) != p.rv.Len() {
+		p.keys = make([]interface{}, p.rv.Len())
+	}
+	p.ReSort()
+}
 
-	pos := p.Search(v, 0)
-	if pos == p.rv.Len() || !p.equal(p.rv.Index(pos).Interface(), v) {
-		return
+// reindexKey is the key cached by Reindex, with the element to break ties.
+type reindexKey struct {
+	key float64
+	v   interface{}
+}
+
+// UnionReportConflicts returns the union with s, keeping the element of the
+// set where both have one, and as a slice those elements of the set whose
+// counterpart in s differs by equal, such as struct values sharing a key.
+func (p *set) UnionReportConflicts(s Set, equal func(a, b interface{}) bool) (result Set, conflicts interface{}) {
+	rv := p.rv
+	if !rv.IsValid() {
+		rv = valueOf(s)
 	}
-	p.rv = ReflectErase(p.rv, pos)
-	deled = 1
-	return
+	if !rv.IsValid() {
+		return p.Zero(), nil
+	}
+	dst := reflect.MakeSlice(rv.Type(), 0, 0)
+	result = p.UnionMerge(s, func(a, b interface{}) interface{} {
+		if !equal(a, b) {
+			dst = reflect.Append(dst, reflect.ValueOf(a))
+		}
+		return a
+	})
+	return result, dst.Interface()
 }
 
-func (p *set) EraseSlice(slice interface{}, sorted bool) (deled int) {
-	if p.rv.Len() == 0 {
-		return
+// WindowAggregate returns, for each element e of an ascending numeric set,
+// agg of the elements in [e, e+width] as a slice sharing the backing array.
+// The results form a slice of the type of the first one, or nil for an
+// empty or non-numeric set. The window slides in a single pass.
+func (p set) WindowAggregate(width interface{}, agg func(window interface{}) interface{}) interface{} {
+	wv := reflect.ValueOf(width)
+	if p.Len() == 0 || !isNumeric(p.rv.Type().Elem().Kind()) || !wv.IsValid() || !isNumeric(wv.Kind()) {
+		return nil
+	}
+	var dst reflect.Value
+	j := 0
+	for i := 0; i < p.Len(); i++ {
+		bound := addNumeric(p.rv.Index(i), wv).Interface()
+		for j < p.Len() && !p.less(bound, p.rv.Index(j).Interface()) {
+			j++
+		}
+		r := reflect.ValueOf(agg(p.rv.Slice(i, j).Interface()))
+		if i == 0 {
+			dst = reflect.MakeSlice(reflect.SliceOf(r.Type()), 0, p.Len())
+		}
+		dst = reflect.Append(dst, r)
 	}
+	return dst.Interface()
+}
 
-	if !sorted {
-		p.sort(slice)
+// WouldChange reports whether inserting slice would add any element, as
+// WouldAdd does for one, walking a sorted copy of slice along the set and
+// stopping at the first such element. slice is left as is.
+func (p *set) WouldChange(slice interface{}) bool {
+	return p.wouldChange(slice, func(v interface{}) bool { return p.admit(v) == nil })
+}
+
+// wouldChange is WouldChange, add telling whether an absent element would
+// be added.
+func (p *set) wouldChange(slice interface{}, add func(v interface{}) bool) bool {
+	rv := reflect.ValueOf(p.normalize(slice))
+	if lenOf(rv) == 0 {
+		return false
 	}
-	rv := reflect.ValueOf(slice)
+	arr := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+	reflect.Copy(arr, rv)
+	p.sort(arr.Interface())
 	pos := 0
-	for i := 0; i < rv.Len() && pos < p.rv.Len(); i++ {
-		v := rv.Index(i).Interface()
+	for i := 0; i < arr.Len(); i++ {
+		v := arr.Index(i).Interface()
 		pos += p.Search(v, pos)
-		if pos == p.rv.Len() || !p.equal(p.rv.Index(pos).Interface(), v) {
-			continue
+		if pos == p.Len() || !p.equal(p.rv.Index(pos).Interface(), v) {
+			if add(v) {
+				return true
+			}
 		}
-		p.rv = ReflectErase(p.rv, pos)
-		deled++
 	}
+	return false
+}
 
-	return
+// IntGaps returns the maximal runs of integers missing between the least
+// and greatest elements of an ascending integer set, as inclusive [start,
+// end] pairs, or nil for other element kinds.
+func (p set) IntGaps() [][2]int {
+	at := intAt(p.rv)
+	if at == nil {
+		return nil
+	}
+	gaps := [][2]int{}
+	for i := 1; i < p.rv.Len(); i++ {
+		if prev, cur := at(i-1), at(i); cur-prev > 1 {
+			gaps = append(gaps, [2]int{prev + 1, cur - 1})
+		}
+	}
+	return gaps
 }
 
-func (p set) Equal(slice interface{}) bool {
-	rv := reflect.ValueOf(slice)
-	if p.rv.Len() != rv.Len() {
+// EqualSetBy reports whether the set and s have the same length and eq
+// holds for the elements at each index, such as an eq ignoring volatile
+// fields. It is only meaningful if both sets are in the same order.
+func (p set) EqualSetBy(s Set, eq func(a, b interface{}) bool) bool {
+	rv := valueOf(s)
+	if p.Len() != lenOf(rv) {
 		return false
 	}
-	for i := 0; i < p.rv.Len(); i++ {
-		if !p.equal(p.rv.Index(i).Interface(),
-			rv.Index(i).Interface()) {
+	for i := 0; i < p.Len(); i++ {
+		if !eq(p.rv.Index(i).Interface(), rv.Index(i).Interface()) {
 			return false
 		}
 	}
 	return true
 }
 
-func (p set) Clone() Set {
-	rv := reflect.MakeSlice(p.rv.Type(), p.rv.Len(), p.rv.Len())
-	reflect.Copy(rv, p.rv)
-	return p.new(rv, p.swaper)
-}
-
-func (p *set) Intersection(s Set) Set {
-	pos := 0
-	rv := s.(*set).rv
-	dst := reflect.Zero(p.rv.Type())
-	for i := 0; i < rv.Len() && pos < p.rv.Len(); i++ {
-		e := rv.Index(i).Interface()
-		pos += p.Search(e, pos)
-
-		if pos == p.rv.Len() {
+// DistanceTo returns the length of the symmetric difference with s without
+// building it, 0 for equal sets.
+func (p set) DistanceTo(s Set) (n int) {
+	rv := valueOf(s)
+	i, j := 0, 0
+	for i < p.Len() && j < lenOf(rv) {
+		a, b := p.rv.Index(i).Interface(), rv.Index(j).Interface()
+		switch {
+		case p.equal(a, b):
+			i++
+			j++
 			continue
+		case p.less(a, b):
+			i++
+		default:
+			j++
 		}
-		v := p.rv.Index(pos)
-		if p.equal(v.Interface(), e) {
-			dst = reflect.Append(dst, v)
-		}
+		n++
 	}
-	return p.new(dst, p.swaper)
+	return n + p.Len() - i + lenOf(rv) - j
 }
 
-func (p *set) new(rv reflect.Value, swaper func(i, j int)) *set {
-	return &set{
-		lessFunc: p.lessFunc,
-		less:     p.less,
-		equal:    p.equal,
-		swaper:   swaper,
-		rv:       rv,
+// IsContiguousWith reports whether the union of two ascending integer sets
+// has every integer between its least and greatest elements, walking both
+// in order. It is false for other element kinds, unless both are empty.
+func (p set) IsContiguousWith(s Set) bool {
+	rv := valueOf(s)
+	if p.Len() == 0 && lenOf(rv) == 0 {
+		return true
 	}
-}
-
-func (p *set) Zero() Set {
-	return p.new(reflect.Zero(p.rv.Type()), p.swaper)
-}
-
-func (p *set) New(slice interface{}, sorted bool) Set {
-	swaper := p.swaper
-	if !p.rv.IsValid() {
-		swaper = reflect.Swapper(slice)
+	left, right := intAt(p.rv), intAt(rv)
+	if (left == nil && p.Len() > 0) || (right == nil && lenOf(rv) > 0) {
+		return false
 	}
-	if sorted {
-		return p.new(reflect.ValueOf(slice), swaper)
+	i, j := 0, 0
+	started, prev := false, 0
+	for i < p.Len() || j < lenOf(rv) {
+		var cur int
+		switch {
+		case j == lenOf(rv) || (i < p.Len() && left(i) <= right(j)):
+			cur = left(i)
+			i++
+		default:
+			cur = right(j)
+			j++
+		}
+		if started && cur > prev+1 {
+			return false
+		}
+		started, prev = true, cur
 	}
-	s := p.new(reflect.Zero(reflect.TypeOf(slice)), swaper)
-	s.Insert(slice)
-	return s
-}
-
-func (p *set) SetSlice(slice interface{}) Set {
-	p.rv = reflect.ValueOf(slice)
-	return p
+	return true
 }
 
-func (p *set) ReSort() {
-	p.sort(p.Slice())
+// LocatorFor returns a Locator of the element equal to v.
+func (p *set) LocatorFor(v interface{}) Locator {
+	return setLocator{s: p, v: v}
 }
 
 var (
@@ -621,4 +3349,18 @@ var (
 			func(s1, s2 interface{}) bool { return s1.(float64) < s2.(float64) },
 		)
 	}
+	// Bytes is Uint8s, byte being uint8.
+	Bytes = func(arr []byte) Set {
+		return Uint8s(arr)
+	}
+	// Runes is Int32s, rune being int32.
+	Runes = func(arr []rune) Set {
+		return Int32s(arr)
+	}
+	// Durations ...
+	Durations = func(arr []time.Duration) Set {
+		return New(arr,
+			func(s1, s2 interface{}) bool { return s1.(time.Duration) < s2.(time.Duration) },
+		)
+	}
 )
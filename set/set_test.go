@@ -1,9 +1,15 @@
 package set_test
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
+	"math"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jettyu/gosc/set"
 )
@@ -121,6 +127,38 @@ func TestIntersection(t *testing.T) {
 	}
 }
 
+func TestIntersectionWrapped(t *testing.T) {
+	s := set.Ints([]int{1, 2, 3, 4})
+	loading := set.NewLoading(intLess, nil, func(key interface{}) (interface{}, bool) { return key, true })
+	for _, c := range []struct {
+		arg    set.Set
+		except []int
+	}{
+		{set.NewSafe(set.Ints([]int{2, 4, 6})), []int{2, 4}},
+		{set.NewBounded(2, set.EvictMin, []int{1, 3, 5}, intLess), []int{3}},
+		{loading, nil},
+	} {
+		if sec := s.Intersection(c.arg); !sec.Equal(c.except) {
+			t.Fatal(c.arg.Slice(), sec.Slice())
+		}
+	}
+	loading.GetOrLoad(1)
+	if sec := s.Intersection(loading); !sec.Equal([]int{1}) {
+		t.Fatal(sec.Slice())
+	}
+}
+
+func TestSafeIntersection(t *testing.T) {
+	safe := set.NewSafe(set.Ints([]int{1, 2, 3}))
+	sec := safe.Intersection(set.Ints([]int{2, 3, 4}))
+	if !sec.Equal([]int{2, 3}) || !safe.Equal([]int{1, 2, 3}) {
+		t.Fatal(sec.Slice(), safe.Slice())
+	}
+	if sec = safe.Intersection(safe); !sec.Equal([]int{1, 2, 3}) {
+		t.Fatal(sec.Slice())
+	}
+}
+
 func TestReflectErase(t *testing.T) {
 	arr := []int{0, 1, 2, 3, 4, 5}
 	rv := reflect.ValueOf(arr)
@@ -170,3 +208,1665 @@ func TestReplace(t *testing.T) {
 		t.Fatal(testStructSet.Slice())
 	}
 }
+
+func TestIntersectionFunc(t *testing.T) {
+	isPrime := func(v interface{}) bool {
+		n := v.(int)
+		if n < 2 {
+			return false
+		}
+		for i := 2; i*i <= n; i++ {
+			if n%i == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	s := set.Ints([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13})
+	primes := s.IntersectionFunc(isPrime)
+	if !primes.Equal([]int{2, 3, 5, 7, 11, 13}) {
+		t.Fatal(primes.Slice())
+	}
+	if s.Len() != 14 {
+		t.Fatal(s.Slice())
+	}
+	safe := set.NewSafe(s).IntersectionFunc(isPrime)
+	if !safe.Equal([]int{2, 3, 5, 7, 11, 13}) {
+		t.Fatal(safe.Slice())
+	}
+}
+
+func TestReplaceIf(t *testing.T) {
+	type versioned struct {
+		ID      int
+		Version int
+	}
+	s := set.New([]versioned{{1, 1}, {2, 3}},
+		func(s1, s2 interface{}) bool { return s1.(versioned).ID < s2.(versioned).ID },
+		func(s1, s2 interface{}) bool { return s1.(versioned).ID == s2.(versioned).ID },
+	)
+	newer := func(existing, incoming interface{}) bool {
+		return incoming.(versioned).Version > existing.(versioned).Version
+	}
+	if !s.ReplaceIf(versioned{1, 2}, newer) {
+		t.Fatal(s.Slice())
+	}
+	if s.ReplaceIf(versioned{2, 2}, newer) {
+		t.Fatal(s.Slice())
+	}
+	if s.ReplaceIf(versioned{3, 1}, newer) {
+		t.Fatal(s.Slice())
+	}
+	if !s.Equal([]versioned{{1, 2}, {2, 3}}) || s.Slice().([]versioned)[1].Version != 3 {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestForEachBatch(t *testing.T) {
+	s := set.Ints([]int{5, 1, 4, 2, 3})
+	var got [][]interface{}
+	s.ForEachBatch(2, func(batch []interface{}) bool {
+		got = append(got, batch)
+		return true
+	})
+	if !reflect.DeepEqual(got, [][]interface{}{{1, 2}, {3, 4}, {5}}) {
+		t.Fatal(got)
+	}
+
+	safe := set.NewSafe(set.Ints([]int{}))
+	for i := 0; i < 1000; i += 2 {
+		safe.Insert(i)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i < 1000; i += 2 {
+			safe.Insert(i)
+		}
+	}()
+	n := 0
+	safe.ForEachBatch(16, func(batch []interface{}) bool {
+		n += len(batch)
+		return true
+	})
+	wg.Wait()
+	if n < 500 || n > 1000 {
+		t.Fatal(n)
+	}
+	if safe.Len() != 1000 {
+		t.Fatal(safe.Len())
+	}
+}
+
+func TestSearchHint(t *testing.T) {
+	s := set.Ints([]int{1, 3, 5, 7, 9, 11})
+	query := []int{0, 3, 4, 9, 10, 12}
+	hint := 0
+	for _, v := range query {
+		var index int
+		index, hint = s.SearchHint(v, hint)
+		if expect := s.Search(v, 0); index != expect {
+			t.Fatal(v, index, expect)
+		}
+	}
+	if index, next := s.SearchHint(5, -3); index != 2 || next != 3 {
+		t.Fatal(index, next)
+	}
+	if index, next := s.SearchHint(5, 100); index != 6 || next != 6 {
+		t.Fatal(index, next)
+	}
+}
+
+func TestIntersectionReSort(t *testing.T) {
+	arr1 := make([]int, 0, 1000)
+	arr2 := make([]int, 0, 500)
+	for i := 0; i < 1000; i++ {
+		arr1 = append(arr1, i)
+		if i%2 == 0 {
+			arr2 = append(arr2, i)
+		}
+	}
+	s := set.Ints(arr1)
+	ins := s.Intersection(set.Ints(arr2))
+	if ins.Len() != 500 {
+		t.Fatal(ins.Len())
+	}
+	slice := ins.Slice().([]int)
+	for i, j := 0, len(slice)-1; i < j; i, j = i+1, j-1 {
+		slice[i], slice[j] = slice[j], slice[i]
+	}
+	ins.ReSort()
+	if !ins.Equal(arr2) {
+		t.Fatal(ins.Slice())
+	}
+	if !s.Equal(arr1) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestCloneReSort(t *testing.T) {
+	s := set.Ints([]int{1, 3, 5})
+	clone := s.Clone()
+	clone.Insert(7, 9, 0)
+	clone.Erase(3)
+	slice := clone.Slice().([]int)
+	slice[0], slice[len(slice)-1] = slice[len(slice)-1], slice[0]
+	clone.ReSort()
+	if !clone.Equal([]int{0, 1, 5, 7, 9}) {
+		t.Fatal(clone.Slice())
+	}
+	if !s.Equal([]int{1, 3, 5}) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestNilEmpty(t *testing.T) {
+	less := func(s1, s2 interface{}) bool { return s1.(int) < s2.(int) }
+	s := set.New(nil, less)
+	if s.Len() != 0 || s.Cap() != 0 || s.Slice() != nil {
+		t.Fatal(s.Slice())
+	}
+	if s.Erase(1, []int{2}) != 0 {
+		t.Fatal(s.Slice())
+	}
+	if s.Has(1, 0) || s.Search(1, 0) != 0 {
+		t.Fatal(s.Slice())
+	}
+	if s.Intersection(set.Ints([]int{1})).Len() != 0 {
+		t.Fatal(s.Slice())
+	}
+	if s.IntersectionFunc(func(interface{}) bool { return true }).Len() != 0 {
+		t.Fatal(s.Slice())
+	}
+	if s.Clone().Len() != 0 || s.Zero().Len() != 0 {
+		t.Fatal(s.Slice())
+	}
+	if !s.Equal([]int{}) {
+		t.Fatal(s.Slice())
+	}
+	s.ReSort()
+	if s.Replace(3) != 1 || s.Insert([]int{2, 1}) != 2 {
+		t.Fatal(s.Slice())
+	}
+	if !s.Equal([]int{1, 2, 3}) {
+		t.Fatal(s.Slice())
+	}
+	if set.New(nil, less).Insert(5, 4) != 2 {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestArray(t *testing.T) {
+	arr := [4]int{3, 1, 2, 1}
+	s := set.New(arr, func(s1, s2 interface{}) bool { return s1.(int) < s2.(int) })
+	if !s.Equal([]int{1, 2, 3}) {
+		t.Fatal(s.Slice())
+	}
+	if _, ok := s.Slice().([]int); !ok {
+		t.Fatal(s.Slice())
+	}
+	if arr != [4]int{3, 1, 2, 1} {
+		t.Fatal(arr)
+	}
+	if s.Insert(0) != 1 || !s.Has(0, 0) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	s := set.Ints([]int{50, 10, 40, 20, 30})
+	for q, expect := range map[float64]int{0: 10, 0.5: 30, 0.9: 40, 1: 50} {
+		if v, ok := s.Quantile(q); !ok || v != expect {
+			t.Fatal(q, v, ok)
+		}
+	}
+	for _, q := range []float64{-0.5, 1.5, math.NaN()} {
+		if _, ok := s.Quantile(q); ok {
+			t.Fatal(q)
+		}
+	}
+	if _, ok := set.Ints([]int{}).Quantile(0.5); ok {
+		t.Fatal("empty set has no quantile")
+	}
+}
+
+func TestChunks(t *testing.T) {
+	s := set.Ints([]int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0})
+	chunks := s.Chunks(3)
+	if len(chunks) != 3 {
+		t.Fatal(len(chunks))
+	}
+	var all []int
+	for i, c := range chunks {
+		if expect := []int{4, 3, 3}[i]; c.Len() != expect {
+			t.Fatal(i, c.Slice())
+		}
+		all = append(all, c.Slice().([]int)...)
+	}
+	if !s.Equal(all) {
+		t.Fatal(all)
+	}
+	chunks[0].Insert(100)
+	if s.Len() != 10 {
+		t.Fatal(s.Slice())
+	}
+	if len(s.Chunks(20)) != 10 || s.Chunks(0) != nil {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	s := set.Int64s([]int64{3, 1, 2})
+	s.ShrinkToFit()
+	if s.Cap() != 3 || s.ByteSize() != 24 {
+		t.Fatal(s.Cap(), s.ByteSize())
+	}
+	s.Reserve(10)
+	if s.Cap() < 13 || s.ByteSize() != s.Cap()*8 {
+		t.Fatal(s.Cap(), s.ByteSize())
+	}
+	reserved := s.ByteSize()
+	s.Reserve(5)
+	if s.ByteSize() != reserved {
+		t.Fatal(s.ByteSize())
+	}
+	s.ShrinkToFit()
+	if s.ByteSize() != 24 || !s.Equal([]int64{1, 2, 3}) {
+		t.Fatal(s.ByteSize(), s.Slice())
+	}
+}
+
+func TestMissing(t *testing.T) {
+	s := set.Ints([]int{1, 3, 5, 7})
+	missing := s.Missing([]int{8, 1, 2, 7, 4, 0})
+	if !reflect.DeepEqual(missing, []int{0, 2, 4, 8}) {
+		t.Fatal(missing)
+	}
+	if missing := s.Missing([]int{3, 5}); !reflect.DeepEqual(missing, []int{}) {
+		t.Fatal(missing)
+	}
+	if missing := set.Ints([]int{}).Missing([]int{1}); !reflect.DeepEqual(missing, []int{1}) {
+		t.Fatal(missing)
+	}
+}
+
+func TestMergeJoin(t *testing.T) {
+	var visits [][3]interface{}
+	visit := func(left, right interface{}, side int) {
+		visits = append(visits, [3]interface{}{left, right, side})
+	}
+	set.Ints([]int{1, 2, 4, 6}).MergeJoin(set.Ints([]int{2, 3, 6, 7}), visit)
+	expect := [][3]interface{}{
+		{1, nil, -1},
+		{2, 2, 0},
+		{nil, 3, 1},
+		{4, nil, -1},
+		{6, 6, 0},
+		{nil, 7, 1},
+	}
+	if !reflect.DeepEqual(visits, expect) {
+		t.Fatal(visits)
+	}
+	visits = nil
+	safe := set.NewSafe(set.Ints([]int{1}))
+	safe.MergeJoin(safe, visit)
+	if !reflect.DeepEqual(visits, [][3]interface{}{{1, 1, 0}}) {
+		t.Fatal(visits)
+	}
+}
+
+func TestSince(t *testing.T) {
+	s := set.NewSafe(set.Ints([]int{1, 2, 3, 4}))
+	snap := s.Snapshot()
+	s.Insert(5, 0)
+	s.Erase(2, 4)
+	added, removed := s.Since(snap)
+	if !reflect.DeepEqual(added, []int{0, 5}) || !reflect.DeepEqual(removed, []int{2, 4}) {
+		t.Fatal(added, removed)
+	}
+	if !snap.Equal([]int{1, 2, 3, 4}) {
+		t.Fatal(snap.Slice())
+	}
+	added, removed = s.Since(s.Snapshot())
+	if !reflect.DeepEqual(added, []int{}) || !reflect.DeepEqual(removed, []int{}) {
+		t.Fatal(added, removed)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	s := set.Ints([]int{1, 2, 3, 4, 5})
+	slice := s.Slice().([]int)
+	slice[1], slice[3] = 1, 3
+	if n := s.Dedup(); n != 2 {
+		t.Fatal(n, s.Slice())
+	}
+	if !s.Equal([]int{1, 3, 5}) || s.Dedup() != 0 {
+		t.Fatal(s.Slice())
+	}
+	if set.Ints([]int{}).Dedup() != 0 {
+		t.Fatal("empty set")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	s1 := set.Ints([]int{3, 1, 2})
+	s2 := set.Ints([]int{})
+	s2.Reserve(100)
+	s2.Insert(2, 3, 1, 2)
+	if s1.Checksum() != s2.Checksum() || len(s1.Checksum()) != 64 {
+		t.Fatal(s1.Checksum(), s2.Checksum())
+	}
+	s2.Erase(3)
+	if s1.Checksum() == s2.Checksum() {
+		t.Fatal(s2.Slice())
+	}
+	if set.Ints([]int{12}).Checksum() == set.Ints([]int{1, 2}).Checksum() {
+		t.Fatal("elements are not separated")
+	}
+	// sha256 of the empty input
+	if sum := set.Ints([]int{}).Checksum(); sum != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatal(sum)
+	}
+}
+
+func TestDrainRange(t *testing.T) {
+	s := set.NewSafe(set.Ints([]int{1, 3, 5, 7, 9, 11}))
+	drained := s.DrainRange(4, 9)
+	if !reflect.DeepEqual(drained, []int{5, 7}) {
+		t.Fatal(drained)
+	}
+	if !s.Equal([]int{1, 3, 9, 11}) {
+		t.Fatal(s.Slice())
+	}
+	if drained := s.DrainRange(20, 30); !reflect.DeepEqual(drained, []int{}) {
+		t.Fatal(drained)
+	}
+	if drained := s.DrainRange(0, 100); !reflect.DeepEqual(drained, []int{1, 3, 9, 11}) || s.Len() != 0 {
+		t.Fatal(drained, s.Slice())
+	}
+}
+
+func TestEraseExact(t *testing.T) {
+	s := set.New([]testStruct{{1, 1}, {2, 2}},
+		func(s1, s2 interface{}) bool { return s1.(testStruct).ID < s2.(testStruct).ID },
+		func(s1, s2 interface{}) bool { return s1.(testStruct).ID == s2.(testStruct).ID },
+	)
+	exact := func(a, b interface{}) bool { return a == b }
+	if s.EraseExact(testStruct{2, 1}, exact) || s.Len() != 2 {
+		t.Fatal(s.Slice())
+	}
+	if s.EraseExact(testStruct{3, 3}, exact) {
+		t.Fatal(s.Slice())
+	}
+	if !s.EraseExact(testStruct{2, 2}, exact) || !reflect.DeepEqual(s.Slice(), []testStruct{{1, 1}}) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestFlushBelow(t *testing.T) {
+	s := set.Ints([]int{})
+	s.Insert([]int{4, 1, 7, 3, 9})
+	if flushed := s.FlushBelow(5); !reflect.DeepEqual(flushed, []int{1, 3, 4}) {
+		t.Fatal(flushed)
+	}
+	if !s.Equal([]int{7, 9}) {
+		t.Fatal(s.Slice())
+	}
+	s.Insert(6, 8)
+	if flushed := s.FlushBelow(7); !reflect.DeepEqual(flushed, []int{6}) || !s.Equal([]int{7, 8, 9}) {
+		t.Fatal(flushed, s.Slice())
+	}
+	if flushed := s.FlushBelow(0); !reflect.DeepEqual(flushed, []int{}) {
+		t.Fatal(flushed)
+	}
+}
+
+func TestToSlice(t *testing.T) {
+	s := set.Ints([]int{3, 1, 2})
+	slice := s.ToSlice().([]int)
+	slice[0] = 100
+	if !s.Equal([]int{1, 2, 3}) {
+		t.Fatal(s.Slice())
+	}
+	if !reflect.DeepEqual(set.NewSafe(s).ToSlice(), []int{1, 2, 3}) {
+		t.Fatal(s.Slice())
+	}
+	if set.New(nil, intLess).ToSlice() != nil {
+		t.Fatal("nil set")
+	}
+}
+
+func TestRank(t *testing.T) {
+	s := set.Ints([]int{10, 20, 30})
+	if s.Rank(20) != 1 || s.Rank(25) != 2 || s.Rank(5) != 0 || s.Rank(40) != 3 {
+		t.Fatal(s.Rank(20), s.Rank(25), s.Rank(5), s.Rank(40))
+	}
+	if set.NewSafe(s).Rank(30) != 2 || set.New(nil, intLess).Rank(1) != 0 {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestForEachCtx(t *testing.T) {
+	arr := make([]int, 1000)
+	for i := range arr {
+		arr[i] = i
+	}
+	s := set.NewSafe(set.Ints(arr))
+	ctx, cancel := context.WithCancel(context.Background())
+	visited := 0
+	err := s.ForEachCtx(ctx, func(i int, v interface{}) bool {
+		visited++
+		if i == 100 {
+			cancel()
+		}
+		return true
+	})
+	if err != context.Canceled || visited <= 100 || visited >= 1000 {
+		t.Fatal(err, visited)
+	}
+	s.Insert(1000) // the read lock was released
+	visited = 0
+	err = s.ForEachCtx(context.Background(), func(i int, v interface{}) bool {
+		visited++
+		return v.(int) < 9
+	})
+	if err != nil || visited != 10 {
+		t.Fatal(err, visited)
+	}
+}
+
+func TestIsSubset(t *testing.T) {
+	s := set.Ints([]int{1, 3})
+	if !s.IsSubset(set.Ints([]int{1, 3})) || s.IsProperSubset(set.Ints([]int{1, 3})) {
+		t.Fatal("equal sets")
+	}
+	if !s.IsSubset(set.Ints([]int{0, 1, 2, 3})) || !set.NewSafe(s).IsProperSubset(set.Ints([]int{1, 2, 3})) {
+		t.Fatal("proper subset")
+	}
+	if s.IsSubset(set.Ints([]int{1, 2, 4})) || s.IsProperSubset(set.Ints([]int{1, 2, 4})) {
+		t.Fatal("not a subset")
+	}
+	if !set.Ints([]int{}).IsProperSubset(s) || set.Ints([]int{}).IsProperSubset(set.Ints([]int{})) {
+		t.Fatal("empty set")
+	}
+}
+
+func TestRefreshOrder(t *testing.T) {
+	priority := map[string]int{"low": 0, "mid": 1, "high": 2}
+	s := set.NewSafe(set.New([]string{"high", "low", "mid"},
+		func(s1, s2 interface{}) bool { return priority[s1.(string)] < priority[s2.(string)] },
+		func(s1, s2 interface{}) bool { return priority[s1.(string)] == priority[s2.(string)] },
+	))
+	if !reflect.DeepEqual(s.Slice(), []string{"low", "mid", "high"}) {
+		t.Fatal(s.Slice())
+	}
+	priority["low"], priority["mid"] = 4, 3
+	s.RefreshOrder()
+	if !reflect.DeepEqual(s.Slice(), []string{"high", "mid", "low"}) || !s.Has("low", 0) {
+		t.Fatal(s.Slice())
+	}
+	priority["low"] = 3
+	s.RefreshOrder()
+	if s.Len() != 2 || priority[s.Slice().([]string)[1]] != 3 {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestToggle(t *testing.T) {
+	s := set.NewSafe(set.Ints([]int{1}))
+	if !s.Toggle(2) || s.Len() != 2 || !s.Has(2, 0) {
+		t.Fatal(s.Slice())
+	}
+	if s.Toggle(2) || s.Len() != 1 || s.Has(2, 0) {
+		t.Fatal(s.Slice())
+	}
+	b := set.NewBounded(2, set.EvictMin, []int{5, 6}, intLess)
+	if b.Toggle(1) || !b.Equal([]int{5, 6}) {
+		t.Fatal(b.Slice())
+	}
+	if !b.Toggle(7) || !b.Equal([]int{6, 7}) {
+		t.Fatal(b.Slice())
+	}
+}
+
+func TestUnionCount(t *testing.T) {
+	for _, c := range [][2][]int{
+		{{1, 2, 3}, {1, 2, 3}},
+		{{1, 3, 5}, {2, 3, 4, 6}},
+		{{1, 2}, {7, 8, 9}},
+		{{}, {1, 2}},
+		{{4}, {}},
+	} {
+		a, b := set.Ints(c[0]), set.Ints(c[1])
+		union := a.Clone()
+		union.Insert(b.Slice())
+		if a.UnionCount(b) != union.Len() || set.NewSafe(b).UnionCount(a) != union.Len() {
+			t.Fatal(c, a.UnionCount(b), union.Slice())
+		}
+	}
+}
+
+func TestBytesRunes(t *testing.T) {
+	b := set.Bytes([]byte("hello"))
+	if !b.Equal([]byte("ehlo")) || !b.Has(byte('l'), 0) || !b.Equal([]uint8{'e', 'h', 'l', 'o'}) {
+		t.Fatal(string(b.Slice().([]byte)))
+	}
+	b.Insert(uint8('a'))
+	if !set.Uint8s([]uint8("ahelo")).Equal(b.Slice()) {
+		t.Fatal(string(b.Slice().([]byte)))
+	}
+	r := set.Runes([]rune("héllo wörld"))
+	if string(r.Slice().([]rune)) != " dhlorwéö" || !r.Has('ö', 0) {
+		t.Fatal(string(r.Slice().([]rune)))
+	}
+}
+
+func TestSymmetricDifferenceIter(t *testing.T) {
+	a, b := set.Ints([]int{1, 2, 4, 6, 8}), set.Ints([]int{2, 3, 4, 9})
+	type sided struct {
+		v    int
+		left bool
+	}
+	collect := func(next func() (interface{}, bool, bool)) (got []sided) {
+		for v, left, ok := next(); ok; v, left, ok = next() {
+			got = append(got, sided{v.(int), left})
+		}
+		return
+	}
+	want := []sided{{1, true}, {3, false}, {6, true}, {8, true}, {9, false}}
+	if got := collect(a.SymmetricDifferenceIter(b)); !reflect.DeepEqual(got, want) {
+		t.Fatal(got)
+	}
+	onlyA := a.Clone()
+	onlyA.Erase(b.Slice())
+	onlyB := b.Clone()
+	onlyB.Erase(a.Slice())
+	if len(want) != onlyA.Len()+onlyB.Len() {
+		t.Fatal(onlyA.Slice(), onlyB.Slice())
+	}
+	if got := collect(set.NewSafe(b).SymmetricDifferenceIter(set.Ints([]int{}))); len(got) != 4 || !got[3].left {
+		t.Fatal(got)
+	}
+	if got := collect(set.New(nil, intLess).SymmetricDifferenceIter(b)); len(got) != 4 || got[0].left {
+		t.Fatal(got)
+	}
+}
+
+func TestCountPresent(t *testing.T) {
+	s := set.Ints([]int{1, 3, 5, 7})
+	if n := s.CountPresent([]int{7, 2, 3, 3, 2, 7, 7, 9}); n != 2 {
+		t.Fatal(n)
+	}
+	if n := set.NewSafe(s).CountPresent([]int{1, 5, 9, 5}); n != 2 {
+		t.Fatal(n)
+	}
+	if n := s.CountPresent([]int{}); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	// the set always holds exactly one of each pair {i, -i}
+	arr := make([]int, 50)
+	for i := range arr {
+		arr[i] = i + 1
+	}
+	s := set.NewSafe(set.Ints(arr))
+	errAbort := fmt.Errorf("abort")
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				v := (g*200+i)%50 + 1
+				err := s.Update(func(s set.Set) error {
+					if s.Has(v, 0) {
+						s.Erase(v)
+						s.Insert(-v)
+					} else {
+						s.Erase(-v)
+						s.Insert(v)
+					}
+					if i%7 == 0 {
+						s.Erase(-v, v)
+						return errAbort
+					}
+					return nil
+				})
+				if err != nil && err != errAbort {
+					t.Error(err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if s.Len() != 50 {
+		t.Fatal(s.Slice())
+	}
+	for i := 1; i <= 50; i++ {
+		if s.Has(i, 0) == s.Has(-i, 0) {
+			t.Fatal(i, s.Slice())
+		}
+	}
+	b := set.NewBounded(2, set.EvictMin, []int{1, 2}, intLess)
+	if err := b.Update(func(s set.Set) error { s.Insert(3); return nil }); err != nil || !b.Equal([]int{2, 3}) {
+		t.Fatal(err, b.Slice())
+	}
+	if err := b.Update(func(s set.Set) error { s.Erase(2); return errAbort }); err != errAbort || !b.Equal([]int{2, 3}) {
+		t.Fatal(err, b.Slice())
+	}
+}
+
+func TestCloneWithCap(t *testing.T) {
+	s := set.Ints([]int{})
+	s.Reserve(100)
+	s.Insert(3, 1, 2)
+	if c := s.Clone(); c.Cap() != 3 {
+		t.Fatal(c.Cap())
+	}
+	c := set.NewSafe(s).CloneWithCap(5)
+	if c.Cap() != 8 || !c.Equal([]int{1, 2, 3}) {
+		t.Fatal(c.Cap(), c.Slice())
+	}
+	c = s.CloneWithCap(5)
+	arr := c.Slice().([]int)
+	c.Insert(0, 4, 6, 5, 7)
+	if c.Cap() != 8 || &c.Slice().([]int)[0] != &arr[:1][0] {
+		t.Fatal(c.Cap(), c.Slice())
+	}
+	if !s.Equal([]int{1, 2, 3}) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestReserveKeepsCapacity(t *testing.T) {
+	s := set.Ints([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	s.Reserve(1000)
+	s.Erase(5)
+	if s.Cap() < 1010 {
+		t.Fatal(s.Cap())
+	}
+	c := set.Ints([]int{1, 2, 3}).CloneWithCap(100)
+	c.Erase(2)
+	if c.Cap() != 103 || !c.Equal([]int{1, 3}) {
+		t.Fatal(c.Cap(), c.Slice())
+	}
+	s.ShrinkToFit()
+	if s.Cap() != 9 {
+		t.Fatal(s.Cap())
+	}
+	s.Erase(0, 1, 2, 3, 4, 6, 7, 8)
+	if s.Cap() >= 9 || !s.Equal([]int{9}) {
+		t.Fatal(s.Cap(), s.Slice())
+	}
+}
+
+func TestIsAliased(t *testing.T) {
+	arr := []int{1, 2, 3}
+	s := set.NewFromSortedNoCopy(arr, intLess)
+	if !s.IsAliased() || !s.Has(2, 0) {
+		t.Fatal(s.Slice())
+	}
+	if s.Clone().IsAliased() || set.Ints(arr).IsAliased() {
+		t.Fatal("copied")
+	}
+	if !set.NewSafe(s.New(arr, true)).IsAliased() || s.New(arr, false).IsAliased() {
+		t.Fatal("New")
+	}
+	s.Erase(2)
+	if !s.IsAliased() || arr[1] != 3 {
+		t.Fatal(arr)
+	}
+	s.Insert(4, 5)
+	if s.IsAliased() {
+		t.Fatal(s.Cap())
+	}
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, fmt.Errorf("full")
+	}
+	w.n--
+	return len(p), nil
+}
+
+func TestWriteUnion(t *testing.T) {
+	a, b := set.Ints([]int{1, 3, 5}), set.Ints([]int{2, 3, 6, 7})
+	format := func(v interface{}) string { return fmt.Sprint(v) }
+	var buf bytes.Buffer
+	if err := set.NewSafe(a).WriteUnion(&buf, b, format); err != nil {
+		t.Fatal(err)
+	}
+	union := a.Clone()
+	union.Insert(b.Slice())
+	want := ""
+	for _, v := range union.Slice().([]int) {
+		want += format(v) + "\n"
+	}
+	if buf.String() != want {
+		t.Fatal(buf.String())
+	}
+	if err := a.WriteUnion(&failingWriter{n: 2}, b, format); err == nil || err.Error() != "full" {
+		t.Fatal(err)
+	}
+}
+
+func TestInsertNil(t *testing.T) {
+	type Item struct{ ID int }
+	s := set.New([]*Item{}, func(s1, s2 interface{}) bool { return s1.(*Item).ID < s2.(*Item).ID })
+	if added, err := s.InsertE(&Item{2}, []*Item{{1}, nil, {3}}); added != 2 || err != set.ErrNilElement {
+		t.Fatal(added, err)
+	}
+	if added, err := s.InsertE(nil); added != 0 || err != set.ErrNilElement {
+		t.Fatal(added, err)
+	}
+	if added, err := s.InsertE((*Item)(nil)); added != 0 || err != set.ErrNilElement || s.Len() != 2 {
+		t.Fatal(added, err)
+	}
+	var iface interface{} = (*Item)(nil)
+	if _, err := set.NewSafe(s).InsertE([]interface{}{iface}); err != set.ErrNilElement {
+		t.Fatal(err)
+	}
+	// Insert and Replace skip nil elements
+	if s.Insert(nil) != 0 || s.Insert((*Item)(nil), []*Item{nil, {4}, nil}) != 1 || s.Replace(nil, []*Item{nil, {1}}) != 0 || s.Len() != 3 {
+		t.Fatal(s.Slice())
+	}
+	b := set.NewBounded(2, set.EvictMin, []*Item{{5}}, func(s1, s2 interface{}) bool { return s1.(*Item).ID < s2.(*Item).ID })
+	if b.Insert(nil, []*Item{nil, {6}}) != 1 || b.Replace(nil) != 0 || b.Len() != 2 {
+		t.Fatal(b.Slice())
+	}
+}
+
+func TestLongestRun(t *testing.T) {
+	s := set.Ints([]int{1, 2, 5, 6, 7, 3})
+	if start, n := s.LongestRun(func(v interface{}) bool { return v.(int) >= 5 }); start != 3 || n != 3 {
+		t.Fatal(start, n)
+	}
+	s.Insert(8, 10, 12, 14, 16)
+	even := func(v interface{}) bool { return v.(int)%2 == 0 }
+	if start, n := set.NewSafe(s).LongestRun(even); start != 6 || n != 5 {
+		t.Fatal(start, n, s.Slice())
+	}
+	if start, n := s.LongestRun(func(v interface{}) bool { return false }); start != -1 || n != 0 {
+		t.Fatal(start, n)
+	}
+}
+
+func TestEraseRangeIf(t *testing.T) {
+	s := set.Ints([]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	even := func(v interface{}) bool { return v.(int)%2 == 0 }
+	if n := s.EraseRangeIf(3, 8, even); n != 2 || !s.Equal([]int{1, 2, 3, 5, 7, 8, 9, 10}) {
+		t.Fatal(n, s.Slice())
+	}
+	if n := set.NewSafe(s).EraseRangeIf(8, 3, even); n != 0 || s.Len() != 8 {
+		t.Fatal(n, s.Slice())
+	}
+	if n := set.New(nil, intLess).EraseRangeIf(0, 1, even); n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	s := set.Ints([]int{10, 20, 30})
+	if below, above, hb, ha := s.Neighbors(25); below != 20 || above != 30 || !hb || !ha {
+		t.Fatal(below, above, hb, ha)
+	}
+	if below, above, hb, ha := set.NewSafe(s).Neighbors(20); below != 20 || above != 20 || !hb || !ha {
+		t.Fatal(below, above, hb, ha)
+	}
+	if below, above, hb, ha := s.Neighbors(5); below != nil || above != 10 || hb || !ha {
+		t.Fatal(below, above, hb, ha)
+	}
+	if below, above, hb, ha := s.Neighbors(35); below != 30 || above != nil || !hb || ha {
+		t.Fatal(below, above, hb, ha)
+	}
+}
+
+func TestDedupReport(t *testing.T) {
+	s := set.New([]testStruct{{1, 1}, {2, 2}, {3, 3}, {4, 4}},
+		func(s1, s2 interface{}) bool { return s1.(testStruct).ID < s2.(testStruct).ID },
+		func(s1, s2 interface{}) bool { return s1.(testStruct).ID == s2.(testStruct).ID },
+	)
+	arr := s.Slice().([]testStruct)
+	arr[1].ID, arr[3].ID = 1, 3
+	removed := s.DedupReport()
+	if !reflect.DeepEqual(removed, []testStruct{{1, 2}, {3, 4}}) || !reflect.DeepEqual(s.Slice(), []testStruct{{1, 1}, {3, 3}}) {
+		t.Fatal(removed, s.Slice())
+	}
+	if removed := set.NewSafe(s).DedupReport(); !reflect.DeepEqual(removed, []testStruct{}) {
+		t.Fatal(removed)
+	}
+}
+
+func TestSearchGallop(t *testing.T) {
+	arr := make([]int, 100)
+	for i := range arr {
+		arr[i] = i * 2
+	}
+	s := set.Ints(arr)
+	for hint := -1; hint <= 101; hint += 7 {
+		for v := -1; v <= 200; v++ {
+			index, next := s.SearchGallop(v, hint)
+			if expect := s.Search(v, 0); index != expect {
+				t.Fatal(v, hint, index, expect)
+			}
+			if expectNext := index + 1 - v%2; v >= 0 && v < 200 && next != expectNext {
+				t.Fatal(v, hint, next)
+			}
+		}
+	}
+	if index, next := set.NewSafe(set.Ints([]int{})).SearchGallop(1, 3); index != 0 || next != 0 {
+		t.Fatal(index, next)
+	}
+}
+
+func BenchmarkSearchGallop(b *testing.B) {
+	arr := make([]int, 1000000)
+	for i := range arr {
+		arr[i] = i
+	}
+	s := set.Ints(arr)
+	query := make([]int, 1000)
+	for i := range query {
+		query[i] = i * 3
+	}
+	b.Run("search", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pos := 0
+			for _, v := range query {
+				pos += s.Search(v, pos)
+			}
+		}
+	})
+	b.Run("gallop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			hint := 0
+			for _, v := range query {
+				_, hint = s.SearchGallop(v, hint)
+			}
+		}
+	})
+}
+
+func TestSetComparator(t *testing.T) {
+	arr := make([]int, 200)
+	for i := range arr {
+		arr[i] = i
+	}
+	s := set.NewSafe(set.Ints(arr))
+	asc := func(s1, s2 interface{}) bool { return s1.(int) < s2.(int) }
+	desc := func(s1, s2 interface{}) bool { return s1.(int) > s2.(int) }
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if !s.Has(i%200, 0) || s.ComparatorName() != "" {
+					t.Error(i % 200)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		if i%2 == 0 {
+			s.SetComparator(desc, nil)
+		} else {
+			s.SetComparator(asc, nil)
+		}
+	}
+	close(done)
+	wg.Wait()
+	named := set.NewWithOptions([]int{1, 2, 2, 3}, asc, nil, set.WithName("asc"))
+	clone := named.Clone()
+	named.SetComparator(func(s1, s2 interface{}) bool { return s1.(int)%2 < s2.(int)%2 },
+		func(s1, s2 interface{}) bool { return s1.(int)%2 == s2.(int)%2 })
+	if named.Len() != 2 || named.ComparatorName() != "" || clone.ComparatorName() != "asc" || clone.Len() != 3 {
+		t.Fatal(named.Slice(), clone.Slice())
+	}
+}
+
+func TestEqualSetOrSlice(t *testing.T) {
+	s := set.Ints([]int{1, 2, 3})
+	unsorted := []int{3, 1, 2}
+	if !s.Equal(unsorted) || !reflect.DeepEqual(unsorted, []int{3, 1, 2}) {
+		t.Fatal(unsorted)
+	}
+	if !s.Equal(set.Ints([]int{2, 3, 1})) || !s.Equal(set.NewSafe(s.Clone())) || !set.NewSafe(s).Equal(s) {
+		t.Fatal(s.Slice())
+	}
+	if s.Equal(set.Ints([]int{1, 2})) || s.Equal([]int{1, 2, 2}) || s.Equal([]int{3, 2, 2}) || s.Equal(nil) {
+		t.Fatal(s.Slice())
+	}
+	if !set.Ints([]int{}).Equal(nil) {
+		t.Fatal("empty")
+	}
+}
+
+func TestUnionMerge(t *testing.T) {
+	less := func(s1, s2 interface{}) bool { return s1.(testStruct).ID < s2.(testStruct).ID }
+	equal := func(s1, s2 interface{}) bool { return s1.(testStruct).ID == s2.(testStruct).ID }
+	a := set.New([]testStruct{{1, 1}, {2, 2}, {4, 4}}, less, equal)
+	b := set.New([]testStruct{{2, 20}, {3, 30}, {4, 40}, {5, 50}}, less, equal)
+	sum := func(x, y interface{}) interface{} {
+		return testStruct{x.(testStruct).ID, x.(testStruct).Value + y.(testStruct).Value}
+	}
+	u := a.UnionMerge(b, sum)
+	want := []testStruct{{1, 1}, {2, 22}, {3, 30}, {4, 44}, {5, 50}}
+	if !reflect.DeepEqual(u.Slice(), want) || a.Len() != 3 || b.Len() != 4 {
+		t.Fatal(u.Slice())
+	}
+	if u := set.NewSafe(b).UnionMerge(set.New(nil, less, equal), sum); !reflect.DeepEqual(u.Slice(), b.Slice()) {
+		t.Fatal(u.Slice())
+	}
+	if u := set.New(nil, less, equal).UnionMerge(a, sum); !reflect.DeepEqual(u.Slice(), a.Slice()) {
+		t.Fatal(u.Slice())
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	arr := []int{1, 2, 3, 4}
+	s := set.NewFromSortedReadOnly(arr, intLess)
+	if !s.IsAliased() || !s.Has(3, 0) {
+		t.Fatal(s.Slice())
+	}
+	s.Erase(2)
+	if !reflect.DeepEqual(arr, []int{1, 2, 3, 4}) || !s.Equal([]int{1, 3, 4}) || s.IsAliased() {
+		t.Fatal(arr, s.Slice())
+	}
+	s.Insert(0)
+	s.Slice().([]int)[0] = 10
+	if !reflect.DeepEqual(arr, []int{1, 2, 3, 4}) {
+		t.Fatal(arr)
+	}
+	for _, write := range []func(s set.Set){
+		func(s set.Set) { s.Insert(5) },
+		func(s set.Set) { s.Replace(2) },
+		func(s set.Set) { s.DrainRange(1, 3) },
+		func(s set.Set) { s.Dedup() },
+		func(s set.Set) { s.ReplaceIf(2, func(existing, incoming interface{}) bool { return true }) },
+	} {
+		s := set.NewFromSortedReadOnly(arr[:2], intLess)
+		write(s)
+		if !reflect.DeepEqual(arr, []int{1, 2, 3, 4}) {
+			t.Fatal(arr, s.Slice())
+		}
+	}
+}
+
+func TestFilterAll(t *testing.T) {
+	arr := make([]int, 100)
+	for i := range arr {
+		arr[i] = i
+	}
+	s := set.Ints(arr)
+	calls := 0
+	preds := []func(v interface{}) bool{
+		func(v interface{}) bool { calls++; return v.(int)%2 == 0 },
+		func(v interface{}) bool { calls++; return v.(int)%3 == 0 },
+		func(v interface{}) bool { calls++; return v.(int) > 10 },
+	}
+	got := s.FilterAll(preds...)
+	if calls != 100+50+17 {
+		t.Fatal(calls)
+	}
+	want := s.IntersectionFunc(preds[0]).IntersectionFunc(preds[1]).IntersectionFunc(preds[2])
+	if !got.Equal(want) || got.Len() != 15 {
+		t.Fatal(got.Slice())
+	}
+	if !set.NewSafe(s).FilterAll().Equal(s) {
+		t.Fatal("no preds")
+	}
+}
+
+func TestWouldAdd(t *testing.T) {
+	s := set.NewSafe(set.Ints([]int{1, 3}))
+	if s.WouldAdd(1) || !s.WouldAdd(2) || s.Len() != 2 {
+		t.Fatal(s.Slice())
+	}
+	bounded := set.NewWithOptions([]int{}, intLess, nil, set.WithBounds(0, 10))
+	if bounded.WouldAdd(11) || !bounded.WouldAdd(10) {
+		t.Fatal(bounded.Slice())
+	}
+}
+
+func TestReset(t *testing.T) {
+	s := set.NewSafe(set.Ints([]int{1, 2, 3}))
+	desc := func(s1, s2 interface{}) bool { return s1.(string) > s2.(string) }
+	arr := []string{"a", "c", "b", "c"}
+	s.Reset(arr, desc, nil)
+	if !reflect.DeepEqual(s.Slice(), []string{"c", "b", "a"}) || !reflect.DeepEqual(arr, []string{"a", "c", "b", "c"}) {
+		t.Fatal(s.Slice())
+	}
+	s.Insert("d", "b")
+	s.Erase("a")
+	if !reflect.DeepEqual(s.Slice(), []string{"d", "c", "b"}) || !s.Has("c", 0) {
+		t.Fatal(s.Slice())
+	}
+	s.Reset(nil, intLess, nil)
+	if s.Len() != 0 || s.Insert(2, 1) != 2 || !s.Equal([]int{1, 2}) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestForEachPair(t *testing.T) {
+	var pairs [][2]interface{}
+	collect := func(cur, next interface{}) bool {
+		pairs = append(pairs, [2]interface{}{cur, next})
+		return true
+	}
+	set.Ints([]int{3, 1, 2}).ForEachPair(collect)
+	if !reflect.DeepEqual(pairs, [][2]interface{}{{1, 2}, {2, 3}}) {
+		t.Fatal(pairs)
+	}
+	pairs = nil
+	set.NewSafe(set.Ints([]int{1})).ForEachPair(collect)
+	set.New(nil, intLess).ForEachPair(collect)
+	if pairs != nil {
+		t.Fatal(pairs)
+	}
+	n := 0
+	set.Ints([]int{1, 2, 3, 4}).ForEachPair(func(cur, next interface{}) bool { n++; return false })
+	if n != 1 {
+		t.Fatal(n)
+	}
+}
+
+func TestDurations(t *testing.T) {
+	s := set.Durations([]time.Duration{time.Second, time.Millisecond, time.Second, time.Microsecond})
+	if !reflect.DeepEqual(s.Slice(), []time.Duration{time.Microsecond, time.Millisecond, time.Second}) {
+		t.Fatal(s.Slice())
+	}
+	latencies := set.Durations([]time.Duration{})
+	for i := 1; i <= 1000; i++ {
+		latencies.Insert(time.Duration(i) * time.Millisecond)
+	}
+	if p99, ok := latencies.Quantile(0.99); !ok || p99 != 990*time.Millisecond {
+		t.Fatal(p99, ok)
+	}
+}
+
+func TestContainsSet(t *testing.T) {
+	s := set.Ints([]int{1, 2, 3, 5, 8})
+	if !s.ContainsSet(set.Ints([]int{2, 8})) || !set.NewSafe(s).ContainsSet(s) {
+		t.Fatal("subset")
+	}
+	if s.ContainsSet(set.Ints([]int{2, 4})) || s.ContainsSet(set.Ints([]int{9})) || s.ContainsSet(set.Ints([]int{0, 1, 2, 3, 5, 8})) {
+		t.Fatal("not a subset")
+	}
+	if !s.ContainsSet(set.Ints([]int{})) || !set.Ints([]int{}).ContainsSet(set.New(nil, intLess)) {
+		t.Fatal("empty")
+	}
+}
+
+func TestQuantiles(t *testing.T) {
+	arr := make([]int, 100)
+	for i := range arr {
+		arr[i] = i + 1
+	}
+	s := set.Ints(arr)
+	if q := s.Quantiles(4); !reflect.DeepEqual(q, []int{25, 50, 75}) {
+		t.Fatal(q)
+	}
+	if q := set.NewSafe(s).Quantiles(3); !reflect.DeepEqual(q, []int{34, 67}) {
+		t.Fatal(q)
+	}
+	if q := set.Ints([]int{7}).Quantiles(4); !reflect.DeepEqual(q, []int{7, 7, 7}) {
+		t.Fatal(q)
+	}
+	if q := s.Quantiles(1); !reflect.DeepEqual(q, []int{}) {
+		t.Fatal(q)
+	}
+}
+
+func TestBatchInsertRCU(t *testing.T) {
+	s := set.NewSafe(set.Ints([]int{-1, -2}))
+	batch := make([]int, 100000)
+	for i := range batch {
+		batch[i] = len(batch) - i
+	}
+	started, done := make(chan struct{}), make(chan struct{})
+	reads := make(chan int)
+	go func() {
+		n := 0
+		for {
+			if l := s.Len(); (l != 2 && l != 100002) || !s.Has(-1, 0) {
+				t.Error(l)
+			}
+			if n++; n == 1 {
+				close(started)
+			}
+			select {
+			case <-done:
+				reads <- n
+				return
+			default:
+			}
+		}
+	}()
+	<-started
+	if added := s.BatchInsertRCU(batch); added != 100000 {
+		t.Fatal(added)
+	}
+	close(done)
+	if n := <-reads; n == 0 {
+		t.Fatal(n)
+	}
+	if s.Len() != 100002 || !s.Has(100000, 0) {
+		t.Fatal(s.Len())
+	}
+	if set.Ints([]int{1}).BatchInsertRCU([]int{2, 3}) != 2 {
+		t.Fatal("plain set")
+	}
+}
+
+func TestStream(t *testing.T) {
+	s := set.NewSafe(set.Ints([]int{3, 1, 2}))
+	var got []int
+	for v := range s.Stream() {
+		got = append(got, v.(int))
+	}
+	if !reflect.DeepEqual(got, s.Slice()) {
+		t.Fatal(got)
+	}
+	ch := s.Stream()
+	if v := <-ch; v != 1 {
+		t.Fatal(v)
+	}
+	// the consumer is paused, which must not block writers
+	s.Insert(0)
+	s.Erase(2)
+	got = nil
+	for v := range ch {
+		got = append(got, v.(int))
+	}
+	if !reflect.DeepEqual(got, []int{2, 3}) || !s.Equal([]int{0, 1, 3}) {
+		t.Fatal(got, s.Slice())
+	}
+	if _, ok := <-set.New(nil, intLess).Stream(); ok {
+		t.Fatal("nil set")
+	}
+}
+
+func TestOverlapRange(t *testing.T) {
+	a := set.Ints([]int{1, 5, 10})
+	if lo, hi, ok := a.OverlapRange(set.Ints([]int{7, 12, 20})); lo != 7 || hi != 10 || !ok {
+		t.Fatal(lo, hi, ok)
+	}
+	if lo, hi, ok := set.NewSafe(a).OverlapRange(set.Ints([]int{3, 4})); lo != 3 || hi != 4 || !ok {
+		t.Fatal(lo, hi, ok)
+	}
+	if lo, hi, ok := a.OverlapRange(set.Ints([]int{10, 11})); lo != 10 || hi != 10 || !ok {
+		t.Fatal(lo, hi, ok)
+	}
+	if _, _, ok := a.OverlapRange(set.Ints([]int{11, 20})); ok {
+		t.Fatal("disjoint")
+	}
+	if _, _, ok := a.OverlapRange(set.Ints([]int{})); ok {
+		t.Fatal("empty")
+	}
+}
+
+func TestCoalesceBy(t *testing.T) {
+	type entry struct {
+		Time     int
+		Category string
+		Count    int
+	}
+	s := set.New([]entry{{1, "a", 1}, {2, "a", 2}, {3, "b", 5}, {4, "a", 1}, {5, "a", 1}, {6, "a", 1}},
+		func(s1, s2 interface{}) bool { return s1.(entry).Time < s2.(entry).Time },
+	)
+	same := func(a, b interface{}) bool { return a.(entry).Category == b.(entry).Category }
+	sum := func(a, b interface{}) interface{} {
+		e := a.(entry)
+		e.Count += b.(entry).Count
+		return e
+	}
+	if n := set.NewSafe(s).CoalesceBy(same, sum); n != 3 {
+		t.Fatal(n, s.Slice())
+	}
+	if !reflect.DeepEqual(s.Slice(), []entry{{1, "a", 3}, {3, "b", 5}, {4, "a", 3}}) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestAtMany(t *testing.T) {
+	s := set.Ints([]int{10, 20, 30, 40, 50})
+	if elems := s.AtMany([]int{0, 2, 4}); !reflect.DeepEqual(elems, []int{10, 30, 50}) {
+		t.Fatal(elems)
+	}
+	if elems := set.NewSafe(s).AtMany([]int{3, -1, 1, 5}); !reflect.DeepEqual(elems, []int{40, 20}) {
+		t.Fatal(elems)
+	}
+	if elems := set.New(nil, intLess).AtMany([]int{0}); elems != nil {
+		t.Fatal(elems)
+	}
+}
+
+func TestCloneAs(t *testing.T) {
+	byID := set.New([]testStruct{{1, 30}, {2, 10}, {3, 20}, {4, 10}},
+		func(s1, s2 interface{}) bool { return s1.(testStruct).ID < s2.(testStruct).ID },
+	)
+	byValue := set.NewSafe(byID).CloneAs(
+		func(s1, s2 interface{}) bool { return s1.(testStruct).Value < s2.(testStruct).Value },
+		func(s1, s2 interface{}) bool { return s1.(testStruct).Value == s2.(testStruct).Value },
+	)
+	if !reflect.DeepEqual(byValue.Slice(), []testStruct{{2, 10}, {3, 20}, {1, 30}}) || !byValue.Has(testStruct{0, 20}, 0) {
+		t.Fatal(byValue.Slice())
+	}
+	if byID.Len() != 4 || byID.Slice().([]testStruct)[0].ID != 1 {
+		t.Fatal(byID.Slice())
+	}
+}
+
+func TestFirstDiff(t *testing.T) {
+	s := set.Ints([]int{1, 2, 3, 5, 8})
+	i, a, b, equal := s.FirstDiff(set.Ints([]int{1, 2, 3, 6, 8}))
+	if equal || i != 3 || a != 5 || b != 6 {
+		t.Fatal(i, a, b, equal)
+	}
+	i, a, b, equal = s.FirstDiff(set.NewSafe(set.Ints([]int{1, 2, 3, 5})))
+	if equal || i != 4 || a != 8 || b != nil {
+		t.Fatal(i, a, b, equal)
+	}
+	i, _, _, equal = s.FirstDiff(s.Clone())
+	if !equal || i != 5 {
+		t.Fatal(i, equal)
+	}
+}
+
+func TestSplitAt(t *testing.T) {
+	s := set.Ints([]int{1, 2, 3, 4, 5})
+	upper := s.SplitAt(3)
+	if !s.Equal([]int{1, 2}) || !upper.Equal([]int{3, 4, 5}) {
+		t.Fatal(s.Slice(), upper.Slice())
+	}
+	upper.Insert(0)
+	if !upper.Equal([]int{0, 3, 4, 5}) || s.Len() != 2 {
+		t.Fatal(s.Slice(), upper.Slice())
+	}
+	if rest := s.SplitAt(9); rest.Len() != 0 || s.Len() != 2 {
+		t.Fatal(s.Slice(), rest.Slice())
+	}
+}
+
+func TestPairs(t *testing.T) {
+	s := set.Ints([]int{3, 1, 2})
+	var pairs [][2]int
+	s.Pairs(func(a, b interface{}) bool {
+		pairs = append(pairs, [2]int{a.(int), b.(int)})
+		return true
+	})
+	if !reflect.DeepEqual(pairs, [][2]int{{1, 2}, {1, 3}, {2, 3}}) {
+		t.Fatal(pairs)
+	}
+	n := 0
+	set.NewSafe(s).Pairs(func(a, b interface{}) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Fatal(n)
+	}
+}
+
+func TestConvertElem(t *testing.T) {
+	s := set.Ints([]int{3, 1, 2})
+	wide := s.ConvertElem(
+		func(a, b interface{}) bool { return a.(int64) < b.(int64) },
+		func(a, b interface{}) bool { return a == b },
+		func(v interface{}) interface{} { return int64(v.(int)) << 32 },
+	)
+	if !reflect.DeepEqual(wide.Slice(), []int64{1 << 32, 2 << 32, 3 << 32}) {
+		t.Fatal(wide.Slice())
+	}
+	if wide.Insert(int64(5)<<32, int64(1)<<32) != 1 || !wide.Has(int64(5)<<32, 0) {
+		t.Fatal(wide.Slice())
+	}
+	// collisions under conv collapse to one
+	mod := set.NewSafe(s).ConvertElem(
+		func(a, b interface{}) bool { return a.(int64) < b.(int64) },
+		func(a, b interface{}) bool { return a == b },
+		func(v interface{}) interface{} { return int64(v.(int) % 2) },
+	)
+	if !reflect.DeepEqual(mod.Slice(), []int64{0, 1}) || !s.Equal([]int{1, 2, 3}) {
+		t.Fatal(mod.Slice(), s.Slice())
+	}
+	if empty := set.Ints(nil).ConvertElem(nil, nil, nil); empty.Len() != 0 {
+		t.Fatal(empty.Slice())
+	}
+}
+
+func TestRepair(t *testing.T) {
+	s := set.Ints([]int{1, 2, 3, 4, 5, 6})
+	arr := s.Slice().([]int)
+	arr[0], arr[5] = arr[5], arr[0]
+	arr[2] = arr[3]
+	fixed, err := set.NewSafe(s).Repair()
+	if err != nil || fixed != 3 || !s.Equal([]int{1, 2, 4, 5, 6}) || !s.IsSorted() {
+		t.Fatal(fixed, err, s.Slice())
+	}
+	if fixed, err = s.Repair(); err != nil || fixed != 0 {
+		t.Fatal(fixed, err)
+	}
+
+	one, two, three := 1, 2, 3
+	ps := set.New([]*int{&one, &two, &three},
+		func(s1, s2 interface{}) bool { return *s1.(*int) < *s2.(*int) },
+		func(s1, s2 interface{}) bool { return *s1.(*int) == *s2.(*int) },
+	)
+	ps.Slice().([]*int)[1] = nil
+	if fixed, err = ps.Repair(); err != nil || fixed != 1 || ps.Len() != 2 || !ps.Has(&three, 0) {
+		t.Fatal(fixed, err, ps.Slice())
+	}
+}
+
+func TestOrderingInfo(t *testing.T) {
+	kind, desc, name := set.Ints([]int{3, 1, 2}).OrderingInfo()
+	if kind != reflect.Int || desc || name != "" {
+		t.Fatal(kind, desc, name)
+	}
+	kind, desc, _ = set.New([]int{1, 3, 2}, func(s1, s2 interface{}) bool { return s1.(int) > s2.(int) }).OrderingInfo()
+	if kind != reflect.Int || !desc {
+		t.Fatal(kind, desc)
+	}
+	byID := set.NewWithOptions([]testStruct{{2, 1}, {1, 2}},
+		func(s1, s2 interface{}) bool { return s1.(testStruct).ID < s2.(testStruct).ID },
+		nil, set.WithName("byID"))
+	kind, desc, name = set.NewSafe(byID).OrderingInfo()
+	if kind != reflect.Struct || desc || name != "byID" {
+		t.Fatal(kind, desc, name)
+	}
+	if kind, _, _ = set.New(nil, nil).OrderingInfo(); kind != reflect.Invalid {
+		t.Fatal(kind)
+	}
+}
+
+func TestNewSince(t *testing.T) {
+	s := set.NewWithOptions([]int{5, 1, 3},
+		func(s1, s2 interface{}) bool { return s1.(int) < s2.(int) },
+		nil, set.TrackInsertionOrder())
+	first, version := s.NewSince(0)
+	if !reflect.DeepEqual(first, []int{1, 3, 5}) || version != 3 {
+		t.Fatal(first, version)
+	}
+	s.Insert(4, 3, 0)
+	second, next := set.NewSafe(s).NewSince(version)
+	if !reflect.DeepEqual(second, []int{0, 4}) || next != 5 {
+		t.Fatal(second, next)
+	}
+	if none, _ := s.NewSince(next); reflect.ValueOf(none).Len() != 0 {
+		t.Fatal(none)
+	}
+	if none, v := set.Ints([]int{1}).NewSince(0); none != nil || v != 0 {
+		t.Fatal(none, v)
+	}
+}
+
+func TestApplyDiff(t *testing.T) {
+	from := set.Ints([]int{1, 2, 4, 6})
+	to := set.Ints([]int{2, 3, 4, 7, 8})
+	added, removed := from.Diff(to)
+	if !reflect.DeepEqual(added, []int{3, 7, 8}) || !reflect.DeepEqual(removed, []int{1, 6}) {
+		t.Fatal(added, removed)
+	}
+	if net := set.NewSafe(from).ApplyDiff(added, removed); net != 1 || !from.Equal(to) {
+		t.Fatal(net, from.Slice())
+	}
+	if added, removed = from.Diff(to); reflect.ValueOf(added).Len() != 0 || reflect.ValueOf(removed).Len() != 0 {
+		t.Fatal(added, removed)
+	}
+	empty := set.Ints(nil)
+	added, removed = empty.Diff(to)
+	if net := empty.ApplyDiff(added, removed); net != 5 || !empty.Equal(to) {
+		t.Fatal(net, empty.Slice())
+	}
+	if net := to.ApplyDiff(nil, []int{8, 9}); net != -1 || to.Len() != 4 {
+		t.Fatal(net, to.Slice())
+	}
+}
+
+func TestReindex(t *testing.T) {
+	fromMean := func(s set.Set, v interface{}) float64 {
+		sum := 0
+		for _, e := range s.Slice().([]int) {
+			sum += e
+		}
+		return math.Abs(float64(v.(int)) - float64(sum)/float64(s.Len()))
+	}
+	s := set.Ints([]int{7, 1, 6, 2, 4})
+	set.NewSafe(s).Reindex(fromMean)
+	// mean 4, ties by the former ascending order
+	if !reflect.DeepEqual(s.Slice(), []int{4, 2, 6, 1, 7}) || !s.IsSorted() {
+		t.Fatal(s.Slice())
+	}
+	if !s.Has(6, 0) || s.Has(5, 0) || s.Insert(6) != 0 {
+		t.Fatal(s.Slice())
+	}
+	s.Insert(20)
+	s.Reindex(fromMean)
+	// mean 40/6
+	if !reflect.DeepEqual(s.Slice(), []int{7, 6, 4, 2, 1, 20}) || !s.Has(20, 0) {
+		t.Fatal(s.Slice())
+	}
+}
+
+func TestUnionReportConflicts(t *testing.T) {
+	byID := func(arr []testStruct) set.Set {
+		return set.New(arr,
+			func(s1, s2 interface{}) bool { return s1.(testStruct).ID < s2.(testStruct).ID },
+			func(s1, s2 interface{}) bool { return s1.(testStruct).ID == s2.(testStruct).ID },
+		)
+	}
+	a := byID([]testStruct{{1, 10}, {2, 20}, {4, 40}, {5, 50}})
+	b := byID([]testStruct{{2, 20}, {3, 30}, {4, 41}, {5, 0}})
+	u, conflicts := set.NewSafe(a).UnionReportConflicts(b, func(a, b interface{}) bool { return a == b })
+	if !reflect.DeepEqual(u.Slice(), []testStruct{{1, 10}, {2, 20}, {3, 30}, {4, 40}, {5, 50}}) {
+		t.Fatal(u.Slice())
+	}
+	if !reflect.DeepEqual(conflicts, []testStruct{{4, 40}, {5, 50}}) || a.Len() != 4 {
+		t.Fatal(conflicts)
+	}
+	if _, conflicts = byID(nil).UnionReportConflicts(b, nil); reflect.ValueOf(conflicts).Len() != 0 {
+		t.Fatal(conflicts)
+	}
+}
+
+func TestWindowAggregate(t *testing.T) {
+	arr := []int{1, 2, 4, 7, 8, 9, 15, 20}
+	s := set.Ints(arr)
+	sum := func(window interface{}) interface{} {
+		n := 0
+		for _, v := range window.([]int) {
+			n += v
+		}
+		return n
+	}
+	got := set.NewSafe(s).WindowAggregate(3, sum)
+	want := make([]int, len(arr))
+	for i, e := range arr {
+		for _, v := range arr {
+			if v >= e && v <= e+3 {
+				want[i] += v
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatal(got, want)
+	}
+	if got = s.WindowAggregate(int64(0), sum); !reflect.DeepEqual(got, arr) {
+		t.Fatal(got)
+	}
+	if got = set.Ints(nil).WindowAggregate(3, sum); got != nil {
+		t.Fatal(got)
+	}
+}
+
+func TestWouldChange(t *testing.T) {
+	s := set.Ints([]int{1, 3, 5, 7})
+	batch := []int{7, 3, 3, 1}
+	if s.WouldChange(batch) || s.WouldChange([]int{}) || !reflect.DeepEqual(batch, []int{7, 3, 3, 1}) {
+		t.Fatal(batch)
+	}
+	if !set.NewSafe(s).WouldChange([]int{5, 9, 1}) || !set.Ints(nil).WouldChange([]int{1}) || s.Len() != 4 {
+		t.Fatal(s.Slice())
+	}
+	bounded := set.NewWithOptions([]int{1, 2}, func(s1, s2 interface{}) bool { return s1.(int) < s2.(int) },
+		nil, set.WithBounds(0, 5))
+	if bounded.WouldChange([]int{2, 8}) || !bounded.WouldChange([]int{8, 3}) {
+		t.Fatal(bounded.Slice())
+	}
+}
+
+func TestIntGaps(t *testing.T) {
+	if gaps := set.Ints([]int{9, 1, 2, 5, 6}).IntGaps(); !reflect.DeepEqual(gaps, [][2]int{{3, 4}, {7, 8}}) {
+		t.Fatal(gaps)
+	}
+	if gaps := set.NewSafe(set.Ints([]int{3, 4, 5})).IntGaps(); gaps == nil || len(gaps) != 0 {
+		t.Fatal(gaps)
+	}
+	u := set.New([]uint8{10, 12, 200}, func(s1, s2 interface{}) bool { return s1.(uint8) < s2.(uint8) })
+	if gaps := u.IntGaps(); !reflect.DeepEqual(gaps, [][2]int{{11, 11}, {13, 199}}) {
+		t.Fatal(gaps)
+	}
+	if gaps := set.Strings([]string{"a", "c"}).IntGaps(); gaps != nil {
+		t.Fatal(gaps)
+	}
+}
+
+func TestEqualSetBy(t *testing.T) {
+	less := func(s1, s2 interface{}) bool { return s1.(testStruct).ID < s2.(testStruct).ID }
+	a := set.New([]testStruct{{1, 10}, {2, 20}, {3, 30}}, less)
+	b := set.New([]testStruct{{3, 0}, {1, 1}, {2, 2}}, less)
+	byID := func(a, b interface{}) bool { return a.(testStruct).ID == b.(testStruct).ID }
+	if !set.NewSafe(a).EqualSetBy(b, byID) || a.Equal(b) {
+		t.Fatal(a.Slice(), b.Slice())
+	}
+	b.Insert(testStruct{4, 40})
+	if a.EqualSetBy(b, byID) {
+		t.Fatal(b.Slice())
+	}
+	b.Erase(testStruct{4, 40}, testStruct{2, 2})
+	b.Insert(testStruct{5, 20})
+	if a.EqualSetBy(b, byID) {
+		t.Fatal(b.Slice())
+	}
+}
+
+func TestNewGenerated(t *testing.T) {
+	less := func(s1, s2 interface{}) bool { return s1.(int) < s2.(int) }
+	i := -3
+	squares := set.NewGenerated(func() (interface{}, bool) {
+		i++
+		return i * i, true
+	}, func(s set.Set) bool { return s.Len() == 5 }, less)
+	// 1 and 2 repeat the squares of -1 and -2
+	if !squares.Equal([]int{0, 1, 4, 9, 16}) || i != 4 {
+		t.Fatal(squares.Slice(), i)
+	}
+	n := 0
+	done := set.NewGenerated(func() (interface{}, bool) {
+		n++
+		return n, n <= 3
+	}, func(s set.Set) bool { return false }, less)
+	if !done.Equal([]int{1, 2, 3}) {
+		t.Fatal(done.Slice())
+	}
+	if empty := set.NewGenerated(nil, func(s set.Set) bool { return true }, less); empty.Len() != 0 {
+		t.Fatal(empty.Slice())
+	}
+}
+
+func TestDistanceTo(t *testing.T) {
+	s := set.Ints([]int{1, 2, 3, 5, 8})
+	if d := s.DistanceTo(s.Clone()); d != 0 {
+		t.Fatal(d)
+	}
+	if d := set.NewSafe(s).DistanceTo(set.Ints([]int{0, 2, 3, 5, 9, 10})); d != 5 {
+		t.Fatal(d)
+	}
+	if d := s.DistanceTo(set.Ints(nil)); d != 5 {
+		t.Fatal(d)
+	}
+}
+
+func TestIsContiguousWith(t *testing.T) {
+	s := set.Ints([]int{1, 2, 3})
+	if !s.IsContiguousWith(set.Ints([]int{4, 5})) || s.IsContiguousWith(set.Ints([]int{5, 6})) {
+		t.Fatal(s.Slice())
+	}
+	if !set.NewSafe(set.Ints([]int{1, 3, 5})).IsContiguousWith(set.Ints([]int{2, 3, 4})) {
+		t.Fatal()
+	}
+	if !s.IsContiguousWith(set.Ints(nil)) || set.Ints([]int{1, 3}).IsContiguousWith(set.Ints(nil)) {
+		t.Fatal()
+	}
+	if set.Strings([]string{"a"}).IsContiguousWith(set.Strings([]string{"b"})) {
+		t.Fatal()
+	}
+}
+
+func TestSafeSelfArgument(t *testing.T) {
+	s := set.NewSafe(set.Ints([]int{1, 2, 3}))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2000; i++ {
+			s.Insert(i)
+		}
+	}()
+	// each call reads its argument, here the set itself, outside its lock
+	for i := 0; i < 200; i++ {
+		s.UnionCount(s)
+		s.IsSubset(s)
+		s.IsProperSubset(s)
+		s.Equal(s)
+		s.ContainsSet(s)
+		s.DistanceTo(s)
+		s.Diff(s)
+		s.FirstDiff(s)
+		s.WriteUnion(ioutil.Discard, s, func(v interface{}) string { return fmt.Sprint(v) })
+		s.OverlapRange(s)
+		s.UnionMerge(s, func(a, b interface{}) interface{} { return a })
+		s.UnionReportConflicts(s, func(a, b interface{}) bool { return a == b })
+		s.EqualSetBy(s, func(a, b interface{}) bool { return a == b })
+		s.IsContiguousWith(s)
+	}
+	<-done
+	if d := s.DistanceTo(s); d != 0 || !s.IsSubset(s) {
+		t.Fatal(d)
+	}
+}
+
+func TestSafePlainArgumentAllocs(t *testing.T) {
+	arr := make([]int, 100)
+	for i := range arr {
+		arr[i] = i * 2
+	}
+	plain := set.Ints(arr)
+	safe := set.NewSafe(plain.Clone())
+	other := set.Ints(arr[:50])
+	// a plain argument is read as is rather than copied
+	for _, op := range []func(s set.Set){
+		func(s set.Set) { s.UnionCount(other) },
+		func(s set.Set) { s.IsSubset(other) },
+		func(s set.Set) { s.DistanceTo(other) },
+	} {
+		unsafe := testing.AllocsPerRun(100, func() { op(plain) })
+		if n := testing.AllocsPerRun(100, func() { op(safe) }); n > unsafe {
+			t.Fatal(n, unsafe)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package settest
+
+import (
+	"reflect"
+
+	"github.com/jettyu/gosc/set"
+)
+
+// CheckIdempotent reports whether inserting v twice into a clone of s leaves
+// the same elements as inserting it once. s is left unchanged.
+func CheckIdempotent(s set.Set, v interface{}) bool {
+	once := s.Clone()
+	once.Insert(v)
+	twice := s.Clone()
+	twice.Insert(v)
+	twice.Insert(v)
+	return once.Len() == twice.Len() &&
+		reflect.DeepEqual(once.Slice(), twice.Slice())
+}
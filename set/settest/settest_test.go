@@ -0,0 +1,31 @@
+package settest_test
+
+import (
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+	"github.com/jettyu/gosc/set/settest"
+)
+
+func TestCheckIdempotent(t *testing.T) {
+	unique := set.Ints([]int{1, 3, 5})
+	for _, v := range []int{0, 1, 4, 5, 9} {
+		if !settest.CheckIdempotent(unique, v) {
+			t.Fatal(v)
+		}
+	}
+	if !unique.Equal([]int{1, 3, 5}) {
+		t.Fatal(unique.Slice())
+	}
+	// an equal that never matches lets elements repeat
+	dup := set.New([]int{1, 3, 5},
+		func(s1, s2 interface{}) bool { return s1.(int) < s2.(int) },
+		func(s1, s2 interface{}) bool { return false },
+	)
+	if settest.CheckIdempotent(dup, 3) || settest.CheckIdempotent(dup, 4) {
+		t.Fatal(dup.Slice())
+	}
+	if dup.Len() != 3 {
+		t.Fatal(dup.Slice())
+	}
+}
@@ -0,0 +1,39 @@
+package set
+
+// StringSet is a set of string with typed methods alongside the Set ones.
+type StringSet struct {
+	Set
+}
+
+// NewStringSet returns the elements of arr in ascending order.
+func NewStringSet(arr []string) *StringSet {
+	return &StringSet{Strings(arr)}
+}
+
+// Strings returns the elements in order.
+func (p *StringSet) Strings() []string {
+	arr, _ := p.Slice().([]string)
+	return arr
+}
+
+// HasString ...
+func (p *StringSet) HasString(v string) bool {
+	return p.Has(v, 0)
+}
+
+// InsertString ...
+func (p *StringSet) InsertString(v ...string) int {
+	return p.Insert(v)
+}
+
+// EraseString ...
+func (p *StringSet) EraseString(v ...string) int {
+	return p.Erase(v)
+}
+
+// IndexOf returns the index of v, or the index it would be inserted at and
+// false if absent.
+func (p *StringSet) IndexOf(v string) (index int, found bool) {
+	index = p.Search(v, 0)
+	return index, index < p.Len() && p.Strings()[index] == v
+}
@@ -0,0 +1,28 @@
+package set_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestStringSet(t *testing.T) {
+	s := set.NewStringSet([]string{"b", "d"})
+	if s.InsertString("c", "a", "c") != 2 || !reflect.DeepEqual(s.Strings(), []string{"a", "b", "c", "d"}) {
+		t.Fatal(s.Strings())
+	}
+	if !s.HasString("c") || s.HasString("e") {
+		t.Fatal(s.Strings())
+	}
+	if i, ok := s.IndexOf("c"); i != 2 || !ok {
+		t.Fatal(i, ok)
+	}
+	if i, ok := s.IndexOf("bb"); i != 2 || ok {
+		t.Fatal(i, ok)
+	}
+	if s.EraseString("a", "e") != 1 || s.EraseString() != 0 || !s.Equal([]string{"b", "c", "d"}) {
+		t.Fatal(s.Strings())
+	}
+	var _ set.Set = s
+}
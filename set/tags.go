@@ -0,0 +1,83 @@
+package set
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// TagName is the struct tag read by NewByTags.
+const TagName = "set"
+
+// NewByTags builds a set of structs ordered by the fields tagged `set:"key"`,
+// compared in declaration order. Key fields must be of integer, float or
+// string kind.
+func NewByTags(slice interface{}) (Set, error) {
+	typ := reflect.TypeOf(slice)
+	if typ == nil || typ.Kind() != reflect.Slice || typ.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("set: NewByTags needs a slice of struct")
+	}
+	var keys []int
+	elem := typ.Elem()
+	for i := 0; i < elem.NumField(); i++ {
+		f := elem.Field(i)
+		if f.Tag.Get(TagName) != "key" {
+			continue
+		}
+		if compareKind(f.Type.Kind()) == nil {
+			return nil, fmt.Errorf("set: key field %s.%s of type %s is not ordered", elem.Name(), f.Name, f.Type)
+		}
+		keys = append(keys, i)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("set: %s has no field tagged `%s:\"key\"`", elem.Name(), TagName)
+	}
+	compare := func(s1, s2 interface{}) int {
+		v1, v2 := reflect.ValueOf(s1), reflect.ValueOf(s2)
+		for _, i := range keys {
+			f1, f2 := v1.Field(i), v2.Field(i)
+			if c := compareKind(f1.Kind())(f1, f2); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	return New(slice,
+		func(s1, s2 interface{}) bool { return compare(s1, s2) < 0 },
+		func(s1, s2 interface{}) bool { return compare(s1, s2) == 0 },
+	), nil
+}
+
+// compareKind returns a three-way comparison for values of kind k, or nil
+// if k has no natural order.
+func compareKind(k reflect.Kind) func(v1, v2 reflect.Value) int {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v1, v2 reflect.Value) int {
+			return compareOrdered(v1.Int() < v2.Int(), v1.Int() > v2.Int())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(v1, v2 reflect.Value) int {
+			return compareOrdered(v1.Uint() < v2.Uint(), v1.Uint() > v2.Uint())
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(v1, v2 reflect.Value) int {
+			return compareOrdered(v1.Float() < v2.Float(), v1.Float() > v2.Float())
+		}
+	case reflect.String:
+		return func(v1, v2 reflect.Value) int {
+			return compareOrdered(v1.String() < v2.String(), v1.String() > v2.String())
+		}
+	}
+	return nil
+}
+
+func compareOrdered(less, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
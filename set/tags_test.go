@@ -0,0 +1,51 @@
+package set_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestNewByTags(t *testing.T) {
+	type item struct {
+		Name string
+		ID   int `set:"key"`
+	}
+	s, err := set.NewByTags([]item{{"c", 3}, {"a", 1}, {"b", 2}, {"d", 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Slice(), []item{{"a", 1}, {"b", 2}, {"c", 3}}) {
+		t.Fatal(s.Slice())
+	}
+	if !s.Has(item{ID: 2}, 0) || s.Insert(item{"x", 3}) != 0 {
+		t.Fatal(s.Slice())
+	}
+
+	type pair struct {
+		A string `set:"key"`
+		B int    `set:"key"`
+	}
+	s, err = set.NewByTags([]pair{{"b", 1}, {"a", 2}, {"a", 1}, {"a", 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Slice(), []pair{{"a", 1}, {"a", 2}, {"b", 1}}) {
+		t.Fatal(s.Slice())
+	}
+
+	type untagged struct{ ID int }
+	if _, err = set.NewByTags([]untagged{}); err == nil {
+		t.Fatal("untagged struct accepted")
+	}
+	type unordered struct {
+		IDs []int `set:"key"`
+	}
+	if _, err = set.NewByTags([]unordered{}); err == nil {
+		t.Fatal("slice key accepted")
+	}
+	if _, err = set.NewByTags([]int{1}); err == nil {
+		t.Fatal("non struct accepted")
+	}
+}
@@ -0,0 +1,60 @@
+package set
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// TypeError reports a slice of a type which a typed constructor does not
+// take.
+type TypeError struct {
+	// Want is the type expected, nil if no constructor takes Got.
+	Want, Got reflect.Type
+}
+
+func (e *TypeError) Error() string {
+	if e.Want == nil {
+		return fmt.Sprintf("set: no typed constructor for %v", e.Got)
+	}
+	return fmt.Sprintf("set: slice of type %v, want %v", e.Got, e.Want)
+}
+
+// typedConstructors returns the typed constructors, looked up on each call
+// as they are variables.
+func typedConstructors() []interface{} {
+	return []interface{}{
+		Strings, Ints, Int8s, Int16s, Int32s, Int64s,
+		Uints, Uint8s, Uint16s, Uint32s, Uint64s, Float32s, Float64s,
+		Durations,
+	}
+}
+
+// TypedE builds slice with the typed constructor, such as Ints, taking its
+// type, or returns a *TypeError if none does.
+func TypedE(slice interface{}) (Set, error) {
+	typ := reflect.TypeOf(slice)
+	for _, c := range typedConstructors() {
+		if reflect.TypeOf(c).In(0) == typ {
+			return reflect.ValueOf(c).Call([]reflect.Value{reflect.ValueOf(slice)})[0].Interface().(Set), nil
+		}
+	}
+	return nil, &TypeError{Got: typ}
+}
+
+// IntsE is Ints, returning a *TypeError if slice is not a []int.
+func IntsE(slice interface{}) (Set, error) {
+	arr, ok := slice.([]int)
+	if !ok {
+		return nil, &TypeError{Want: reflect.TypeOf(arr), Got: reflect.TypeOf(slice)}
+	}
+	return Ints(arr), nil
+}
+
+// StringsE is Strings, returning a *TypeError if slice is not a []string.
+func StringsE(slice interface{}) (Set, error) {
+	arr, ok := slice.([]string)
+	if !ok {
+		return nil, &TypeError{Want: reflect.TypeOf(arr), Got: reflect.TypeOf(slice)}
+	}
+	return Strings(arr), nil
+}
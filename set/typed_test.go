@@ -0,0 +1,31 @@
+package set_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jettyu/gosc/set"
+)
+
+func TestTypedE(t *testing.T) {
+	_, err := set.IntsE([]int8{1, 2})
+	if err == nil || err.Error() != "set: slice of type []int8, want []int" {
+		t.Fatal(err)
+	}
+	if s, err := set.IntsE([]int{2, 1}); err != nil || !s.Equal([]int{1, 2}) {
+		t.Fatal(err)
+	}
+	if _, err := set.StringsE(nil); err == nil || err.(*set.TypeError).Got != nil {
+		t.Fatal(err)
+	}
+	s, err := set.TypedE([]int8{3, 1, 3})
+	if err != nil || !s.Equal([]int8{1, 3}) {
+		t.Fatal(err)
+	}
+	if s, err := set.TypedE([]time.Duration{time.Second, 0}); err != nil || !s.Equal([]time.Duration{0, time.Second}) {
+		t.Fatal(err)
+	}
+	if _, err := set.TypedE([]complex64{1}); err == nil || err.Error() != "set: no typed constructor for []complex64" {
+		t.Fatal(err)
+	}
+}